@@ -23,6 +23,7 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"time"
 
 	"github.com/i-am-david-fernandez/glog"
@@ -44,6 +45,17 @@ var autoCmd = &cobra.Command{
 		glog.Infof("==== ==== ==== ====")
 		tStart := time.Now()
 
+		spool := resticmanager.AppConfig.NewSpool()
+
+		// Retry delivery of any notifications left over from previous runs.
+		if mailer := resticmanager.AppConfig.NewMailer(); (!rootFlags.noEmail) && (!resticmanager.AppConfig.DryRun) && (mailer != nil) {
+			if result := spool.Flush(mailer, false); result.Sent+result.Failed+result.Expired > 0 {
+				glog.Infof("Spooled notifications: %d sent, %d failed, %d deferred, %d expired.", result.Sent, result.Failed, result.Deferred, result.Expired)
+			}
+		}
+
+		failedProfiles := 0
+
 		for _, profile := range resticmanager.AppConfig.Profiles {
 
 			tProfile := time.Now()
@@ -52,11 +64,11 @@ var autoCmd = &cobra.Command{
 			// Note: we capture everything in the session backend and perform
 			// context-specific filtering later.
 			sessionBackend := glog.NewListBackend("", glog.Debug)
-			glog.SetBackend(logNameSession, sessionBackend)
+			glog.SetBackend(logNameSession, resticmanager.NewRedactingBackend(sessionBackend))
 
 			// Configure profile logging (to file)
 			if logFilename := profile.LogFile(); (!rootFlags.noFileLogging) && (logFilename != "") {
-				glog.SetBackend(logNameProfile, glog.NewFileBackend(logFilename, profile.LogFileAppend(), "", profile.LogFileLevel(), ""))
+				glog.SetBackend(logNameProfile, resticmanager.RedactFileBackend(glog.NewFileBackend(logFilename, profile.LogFileAppend(), "", profile.LogFileLevel(), "")))
 			}
 
 			glog.Infof("---- ---- ---- ----")
@@ -67,6 +79,8 @@ var autoCmd = &cobra.Command{
 
 			restic := resticmanager.NewRestic(resticmanager.AppConfig)
 
+			report := resticmanager.NewReport(profile)
+
 			exists, err := restic.RepoExists(profile)
 			if err != nil {
 				glog.Errorf("Could not determine state of repository path: %v", err)
@@ -78,8 +92,22 @@ var autoCmd = &cobra.Command{
 
 				proceed := true
 
+				// Refuse to proceed with (or initialise) an unexpected repository
+				if err := restic.CheckRepositoryIdentity(profile, exists); err != nil {
+					glog.Errorf("%v", err)
+					report.StartOperation("repository-identity").Finish(err)
+					proceed = false
+				}
+
 				for _, operation := range profile.OperationSequence() {
 
+					if !proceed {
+						break
+					}
+
+					op := report.StartOperation(operation)
+					var opErr error
+
 					switch operation {
 
 					case "initialise":
@@ -91,9 +119,12 @@ var autoCmd = &cobra.Command{
 							if err != nil {
 								glog.Errorf("%v", err)
 								proceed = false
+								opErr = err
 							}
 							glog.Infof(response)
 							exists = true
+						} else {
+							op.Skip()
 						}
 
 					case "unlock":
@@ -102,6 +133,7 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
 
@@ -111,8 +143,10 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
+						report.Backup = resticmanager.NewBackupSummary(response)
 
 					case "check":
 						// Check repo
@@ -120,6 +154,7 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
 
@@ -129,15 +164,38 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
 
+					case "prune":
+						// Prune the repository, if needed
+						summary, pruned, err := restic.PruneIfNeeded(profile)
+						if err != nil {
+							glog.Errorf("%v", err)
+							proceed = false
+							opErr = err
+						} else if !pruned {
+							op.Skip()
+						}
+						report.Prune = summary
+
+					case "verify-restore":
+						// Restore (a sample of) the latest snapshot and verify it
+						verification, err := restic.VerifyRestore(profile)
+						if err != nil {
+							glog.Errorf("%v", err)
+							opErr = err
+						}
+						report.Verify = verification
+
 					case "show-snapshots":
 						// Show snapshots
 						response, err := restic.Snapshots(profile)
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
 
@@ -147,6 +205,7 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Errorf("%v", err)
 							proceed = false
+							opErr = err
 						}
 						glog.Infof(response)
 
@@ -156,9 +215,26 @@ var autoCmd = &cobra.Command{
 						if err != nil {
 							glog.Warningf("Unable to perform diff: %v", err)
 							//proceed = false
+							opErr = err
 						} else if response != nil {
 							glog.Infof("%+v", response.Report)
+
+							// Compare against recent runs (with the snapshot file count, if just backed up)
+							fileCount := 0
+							if report.Backup != nil {
+								fileCount = report.Backup.FilesProcessed
+							}
+							report.Anomalies = resticmanager.DetectAnomalies(profile, response, fileCount)
 						}
+						report.Diff = response
+
+					default:
+						glog.Warningf("Unknown operation %s; ignoring.", operation)
+						op.Skip()
+					}
+
+					if op.Status == "" {
+						op.Finish(opErr)
 					}
 
 					if !proceed {
@@ -166,6 +242,23 @@ var autoCmd = &cobra.Command{
 						break
 					}
 				}
+
+				if exists && !resticmanager.AppConfig.DryRun {
+					if snapshots, err := restic.SnapshotList(profile); err != nil {
+						glog.Warningf("%v", err)
+					} else {
+						report.Snapshots = snapshots
+						report.Pins = resticmanager.PinsOf(snapshots)
+						if err := resticmanager.AppConfig.SavePins(profile.Name(), report.Pins); err != nil {
+							glog.Warningf("Could not save pins: %v", err)
+						}
+					}
+				}
+			}
+
+			report.Finish()
+			if !report.Succeeded() {
+				failedProfiles++
 			}
 
 			tNow := time.Now()
@@ -178,77 +271,36 @@ var autoCmd = &cobra.Command{
 					glog.Infof("Mailing log.")
 					context := fmt.Sprintf("Performing automatic management of profile %s", profile.Name())
 
-					// We will sent a set of emails, each to an independent recipient list and with an independent log level filter.
-					cases := []struct {
-						recipients []string
-						level      glog.LogLevel
-						thresholds map[glog.LogLevel]int
-					}{
-						{
-							// Messages to application-configured recipients
-							resticmanager.AppConfig.EmailRecipients(),
-							resticmanager.AppConfig.EmailLogLevel(),
-							resticmanager.AppConfig.EmailThresholds(),
-						},
-						{
-							// Messages to profile-configured recipients
-							profile.EmailRecipients(),
-							profile.EmailLogLevel(),
-							profile.EmailThresholds(),
-						},
-					}
-
-					data := struct {
-						Preamble   string
-						LogSummary []*glog.RecordSummary
-						LogRecords []glog.Record
-					}{
-						"",
-						sessionBackend.Summary(),
-						nil,
-					}
-
-					for _, c := range cases {
-						if c.recipients != nil {
+					report.LogSummary = sessionBackend.Summary()
 
-							proceed := true
+					// We will sent a set of emails, each to an independent recipient list and with an independent log level filter.
+					for _, c := range emailCases(profile) {
+						if c.recipients != nil && c.isTriggered(report.LogSummary) {
 
-							if len(c.thresholds) > 0 {
-								// We have some configured thresholds.
-								// Assume we should _not_ proceed (to mail)
-								// unless one or more thresholds are exceeded
-								proceed = false
-								for _, bin := range data.LogSummary {
-									if threshold, ok := c.thresholds[bin.Level]; ok {
-										if bin.Count >= threshold {
-											proceed = true
-										}
-									}
-								}
-							}
+							report.LogRecords = sessionBackend.Get(c.level)
+							message := newReportMessage(profile, report, c, context, restic.Output())
 
-							if proceed {
-								message := resticmanager.NewMailMessage()
-								message.Sender = resticmanager.AppConfig.EmailSender()
-								message.AddRecipients(c.recipients...)
-								message.SetContext(context)
-
-								data.Preamble = fmt.Sprintf("Note: only log messages at or above level %s are displayed.", c.level)
-								data.LogRecords = sessionBackend.Get(c.level)
-								message.AddTemplatedContent(resticmanager.AppConfig.EmailTemplate(), data)
-
-								if !rootFlags.noEmail {
-									mailer.SendMessage(message)
-								} else {
-									buffer := []byte(message.Content())
-									ioutil.WriteFile(fmt.Sprintf("%s.html", profile.Name()), buffer, 0600)
-								}
+							if !rootFlags.noEmail {
+								spool.Submit(mailer, message)
+							} else {
+								buffer := []byte(message.Content())
+								ioutil.WriteFile(fmt.Sprintf("%s.html", profile.Name()), buffer, 0600)
 							}
 						}
 					}
 				}
 			}
 
+			if !resticmanager.AppConfig.DryRun {
+				// Retain a record of this run (e.g., for "email preview")
+				report.Preamble = ""
+				report.LogSummary = sessionBackend.Summary()
+				report.LogRecords = sessionBackend.Get(glog.Info)
+				if err := resticmanager.AppConfig.SaveRunRecord(report); err != nil {
+					glog.Warningf("Could not save run record: %v", err)
+				}
+			}
+
 			// Clear/remove profile and session logging backends
 			glog.RemoveBackend(logNameProfile)
 			glog.RemoveBackend(logNameSession)
@@ -257,7 +309,16 @@ var autoCmd = &cobra.Command{
 		tNow := time.Now()
 		elapsed := tNow.Sub(tStart)
 		glog.Infof("Total elapsed time: %v", elapsed)
+
+		// Load errors were logged as they were encountered (see rootCmd), so are only counted here.
+		glog.Infof("Summary: %d profile(s) processed, %d profile(s) failed, %d load error(s).",
+			len(resticmanager.AppConfig.Profiles), failedProfiles, len(resticmanager.AppConfig.LoadErrors))
 		glog.Infof("==== ==== ==== ====")
+
+		if len(resticmanager.AppConfig.LoadErrors) > 0 {
+			// Profiles that could not be loaded were not backed up; make that visible to the caller.
+			os.Exit(1)
+		}
 	},
 }
 
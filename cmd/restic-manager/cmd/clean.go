@@ -31,7 +31,9 @@ import (
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Perform prune operation.",
-	Long:  `Perform prune operation.`,
+	Long: `Perform prune operation, with the profile prune options (prune.max-unused,
+	prune.max-repack-size and prune.repack-cacheable-only). The repository is always pruned;
+	prune.when applies only to the prune operation of the auto command.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("clean called")
 
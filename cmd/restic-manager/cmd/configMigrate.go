@@ -0,0 +1,120 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var configMigrateFlags = struct {
+	write bool
+}{}
+
+// configMigrateCmd represents the config migrate command
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [FILE...]",
+	Short: "Migrate configuration files to the current syntax.",
+	Long: `Migrate configuration files to the current syntax.
+
+	Deprecated "<source>" and "<repo>" substitutions are replaced with their template
+	equivalents ("{{.source}}" and "{{.repo}}"). The (layered) application configuration files, all specified
+	and discovered profile files and any files given as arguments are migrated. Only YAML files
+	are supported; their layout and comments are preserved.
+
+	Note that all profile string values are template-expanded. A value that refers to an unknown
+	key (e.g., "{{.sauce}}") is left unexpanded, with a warning naming the key and its file; such
+	values are not migrated and should be corrected by hand.
+
+	By default, the required changes are only reported. Use --write to rewrite the files
+	(the original of each rewritten file is retained with a ".bak" suffix).`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		files := append([]string{}, resticmanager.AppConfig.ConfigFilesUsed()...)
+		files = append(files, rootFlags.profileFiles...)
+		files = append(files, args...)
+
+		failed := false
+		total := 0
+
+		for _, filename := range files {
+
+			migrated, changes, err := resticmanager.MigrateFile(filename)
+			if err != nil {
+				glog.Errorf("%v", err)
+				failed = true
+				continue
+			}
+
+			for _, change := range changes {
+				fmt.Println(change)
+			}
+			total += len(changes)
+
+			if len(changes) == 0 || !configMigrateFlags.write {
+				continue
+			}
+
+			stat, err := os.Stat(filename)
+			if err != nil {
+				glog.Errorf("%v", err)
+				failed = true
+				continue
+			}
+
+			if err := os.Rename(filename, filename+".bak"); err != nil {
+				glog.Errorf("Could not back up %v: %v", filename, err)
+				failed = true
+				continue
+			}
+
+			if err := ioutil.WriteFile(filename, migrated, stat.Mode()); err != nil {
+				glog.Errorf("Could not write %v: %v", filename, err)
+				failed = true
+				continue
+			}
+
+			glog.Infof("Migrated %v (%d change(s); original retained as %v.bak)", filename, len(changes), filename)
+		}
+
+		if total > 0 && !configMigrateFlags.write {
+			glog.Infof("%d change(s) required; use --write to apply them.", total)
+		} else if total == 0 {
+			glog.Infof("No migration required.")
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configMigrateCmd)
+
+	configMigrateCmd.Flags().BoolVar(&configMigrateFlags.write, "write", false, "rewrite the migrated files")
+}
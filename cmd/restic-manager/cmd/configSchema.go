@@ -0,0 +1,69 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// configSchemaCmd represents the config schema command
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema {app|profile}",
+	Short: "Print the JSON Schema of the application or profile configuration.",
+	Long: `Print the JSON Schema of the application or profile configuration.
+
+	The schema can be used by editors (e.g., via a yaml-language-server "$schema" modeline)
+	to provide completion and validation. Copies are published in the "schema" directory.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"app", "profile"},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		var schema *resticmanager.SchemaNode
+
+		switch args[0] {
+		case "app":
+			schema = resticmanager.AppSchema()
+		case "profile":
+			schema = resticmanager.ProfileSchema()
+		default:
+			glog.Errorf("Unknown schema %q (expected app or profile)", args[0])
+			os.Exit(1)
+		}
+
+		content, err := schema.JSONSchema()
+		if err != nil {
+			glog.Errorf("Could not generate schema: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(content))
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSchemaCmd)
+}
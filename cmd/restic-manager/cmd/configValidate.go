@@ -0,0 +1,90 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [PROFILE-FILE...]",
+	Short: "Validate the application and profile configuration against the schema.",
+	Long: `Validate the application and profile configuration against the schema.
+
+	All (layered) application configuration files and all specified (via --profile) and discovered (via --profile-dir)
+	profile files, including inactive profiles, are validated, as are any additional profile files
+	given as arguments. Unknown keys, wrong value types, invalid log levels, retention periods and
+	operation names are reported with their file and line. The exit status is non-zero if any
+	problem is found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		problems := 0
+
+		for _, filename := range resticmanager.AppConfig.ConfigFilesUsed() {
+			issues, err := resticmanager.ValidateSettingsFile(filename, resticmanager.AppSchema())
+			if err != nil {
+				fmt.Printf("%s: %v\n", filename, err)
+				problems++
+			}
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+			problems += len(issues)
+		}
+		if len(resticmanager.AppConfig.ConfigFilesUsed()) == 0 {
+			glog.Warningf("No application configuration file in use.")
+		}
+
+		// Profile load errors have already been logged (see rootCmd)
+		problems += len(resticmanager.AppConfig.LoadErrors)
+
+		files := append(append([]string{}, rootFlags.profileFiles...), args...)
+		for _, filename := range files {
+			issues, err := resticmanager.ValidateProfileFile(filename)
+			if err != nil {
+				fmt.Printf("%s: %v\n", filename, err)
+				problems++
+			}
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+			problems += len(issues)
+		}
+
+		if problems > 0 {
+			glog.Errorf("Found %d configuration problem(s).", problems)
+			os.Exit(1)
+		}
+
+		glog.Infof("Configuration is valid (%d profile file(s) checked).", len(files))
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
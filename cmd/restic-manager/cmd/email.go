@@ -39,7 +39,7 @@ var emailCmd = &cobra.Command{
 
 		const logNameSession = "session"
 		sessionBackend := glog.NewListBackend("", glog.Debug)
-		glog.SetBackend(logNameSession, sessionBackend)
+		glog.SetBackend(logNameSession, resticmanager.NewRedactingBackend(sessionBackend))
 
 		glog.Debugf("Debug message")
 		glog.Infof("Info message")
@@ -50,15 +50,11 @@ var emailCmd = &cobra.Command{
 
 		level := resticmanager.AppConfig.EmailLogLevel()
 
-		data := struct {
-			Preamble   string
-			LogSummary []*glog.RecordSummary
-			LogRecords []glog.Record
-		}{
-			fmt.Sprintf("Note: only log messages at or above level %s are displayed.", level),
-			sessionBackend.Summary(),
-			sessionBackend.Get(level),
-		}
+		data := resticmanager.NewReport(nil)
+		data.Preamble = fmt.Sprintf("Note: only log messages at or above level %s are displayed.", level)
+		data.LogSummary = sessionBackend.Summary()
+		data.LogRecords = sessionBackend.Get(level)
+		data.Finish()
 
 		context := "Test message from restic-manager."
 
@@ -66,11 +62,14 @@ var emailCmd = &cobra.Command{
 		message.Sender = resticmanager.AppConfig.EmailSender()
 		message.AddRecipients(resticmanager.AppConfig.EmailRecipients()...)
 		message.SetContext(context)
-		message.AddTemplatedContent(resticmanager.AppConfig.EmailTemplate(), data)
+		message.AddLayeredTemplatedContent(resticmanager.AppConfig.EmailTemplates(nil), data)
+		if layers := resticmanager.AppConfig.EmailTextTemplates(nil); len(layers) > 0 {
+			message.AddLayeredTemplatedTextContent(layers, data)
+		}
 
 		if mailer := resticmanager.AppConfig.NewMailer(); (!rootFlags.noEmail) && (mailer != nil) {
 
-			mailer.SendMessage(message)
+			resticmanager.AppConfig.NewSpool().Submit(mailer, message)
 		} else {
 			buffer := []byte(message.Content())
 			ioutil.WriteFile("restic-manager.email.html", buffer, 0600)
@@ -0,0 +1,140 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var emailPreviewFlags struct {
+	outputDir string
+	synthetic bool
+}
+
+// emailPreviewCmd represents the email preview command
+var emailPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Render the email report for the selected profile(s) without sending it.",
+	Long: `Render the email report for the selected profile(s) without sending it.
+
+	The report is rendered against the record of the most-recent "auto" run of each profile
+	or, if there is none (or --synthetic is specified), against synthetic data. For each
+	email that would be sent (to application- and profile-configured recipients), an html
+	file and a complete .eml message are written to the output directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if err := os.MkdirAll(emailPreviewFlags.outputDir, 0700); err != nil {
+			glog.Errorf("Could not create output directory: %v", err)
+			os.Exit(1)
+		}
+
+		for _, profile := range resticmanager.AppConfig.Profiles {
+			glog.Infof("Processing profile %v", profile.Name())
+			glog.Debugf("  from file %v", profile.File())
+
+			var report *resticmanager.Report
+
+			if !emailPreviewFlags.synthetic {
+				record, err := resticmanager.AppConfig.LoadRunRecord(profile.Name())
+				if err != nil {
+					if !os.IsNotExist(err) {
+						glog.Warningf("Could not load run record: %v", err)
+					}
+					glog.Infof("No run record available; using synthetic data.")
+				} else {
+					glog.Infof("Using run record from %v", record.Start.Format("2006-01-02 15:04:05"))
+					report = record
+				}
+			}
+
+			if report == nil {
+				report = resticmanager.NewSyntheticReport(profile)
+			}
+
+			records := report.LogRecords
+			context := fmt.Sprintf("Performing automatic management of profile %s", profile.Name())
+
+			for _, c := range emailCases(profile) {
+				if c.recipients == nil {
+					continue
+				}
+
+				// Filter the recorded log records to the case level
+				report.LogRecords = make([]glog.Record, 0)
+				for _, record := range records {
+					if record.Level >= c.level {
+						report.LogRecords = append(report.LogRecords, record)
+					}
+				}
+
+				message := newReportMessage(profile, report, c, context, nil)
+
+				if !c.isTriggered(report.LogSummary) {
+					glog.Infof("Note: the %s email would not be sent (no threshold exceeded).", c.name)
+				}
+
+				basename := filepath.Join(emailPreviewFlags.outputDir, fmt.Sprintf("%s.%s", profile.Name(), c.name))
+
+				if err := ioutil.WriteFile(basename+".html", []byte(message.Content()), 0600); err != nil {
+					glog.Errorf("Could not write preview: %v", err)
+				} else {
+					glog.Infof("Wrote %s", basename+".html")
+				}
+
+				if text := message.TextContent(); text != "" {
+					if err := ioutil.WriteFile(basename+".txt", []byte(text), 0600); err != nil {
+						glog.Errorf("Could not write preview: %v", err)
+					} else {
+						glog.Infof("Wrote %s", basename+".txt")
+					}
+				}
+
+				handle, err := os.OpenFile(basename+".eml", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+				if err != nil {
+					glog.Errorf("Could not write preview: %v", err)
+					continue
+				}
+
+				if _, err := message.WriteTo(handle); err != nil {
+					glog.Errorf("Could not write preview: %v", err)
+				} else {
+					glog.Infof("Wrote %s", basename+".eml")
+				}
+				handle.Close()
+			}
+		}
+	},
+}
+
+func init() {
+	emailCmd.AddCommand(emailPreviewCmd)
+
+	emailPreviewCmd.Flags().StringVar(&emailPreviewFlags.outputDir, "output-dir", ".", "directory to which previews are written")
+	emailPreviewCmd.Flags().BoolVar(&emailPreviewFlags.synthetic, "synthetic", false, "render against synthetic data, even if a run record is available")
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var notifyFlushFlags struct {
+	force bool
+}
+
+// notifyFlushCmd represents the notify flush command
+var notifyFlushCmd = &cobra.Command{
+	Use:   "flush [ID...]",
+	Short: "Attempt delivery of spooled notifications.",
+	Long: `Attempt delivery of spooled notifications.
+
+	By default, only notifications whose retry backoff has elapsed are attempted; use --force
+	to attempt all. Specific notifications may be selected by (a prefix of) their ID.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		mailer := resticmanager.AppConfig.NewMailer()
+		if mailer == nil {
+			glog.Errorf("No email configuration; cannot deliver notifications.")
+			os.Exit(1)
+		}
+
+		spool := resticmanager.AppConfig.NewSpool()
+
+		if len(args) > 0 {
+			failed := 0
+			for _, id := range args {
+				item, err := spool.Find(id)
+				if err != nil {
+					glog.Errorf("%v", err)
+					failed++
+					continue
+				}
+
+				if err := spool.Deliver(mailer, item); err != nil {
+					glog.Errorf("Could not send notification %s: %v", item.ID, err)
+					failed++
+				} else {
+					glog.Infof("Sent notification %s", item.ID)
+				}
+			}
+
+			if failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		result := spool.Flush(mailer, notifyFlushFlags.force)
+		glog.Infof("Spooled notifications: %d sent, %d failed, %d deferred, %d expired.", result.Sent, result.Failed, result.Deferred, result.Expired)
+
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyFlushCmd)
+
+	notifyFlushCmd.Flags().BoolVar(&notifyFlushFlags.force, "force", false, "attempt delivery regardless of retry backoff")
+}
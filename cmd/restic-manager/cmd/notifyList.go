@@ -0,0 +1,78 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// notifyListCmd represents the notify list command
+var notifyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List spooled (undelivered) notifications.",
+	Long:  `List spooled (undelivered) notifications.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		spool := resticmanager.AppConfig.NewSpool()
+
+		items, err := spool.Items()
+		if err != nil {
+			glog.Errorf("%v", err)
+			os.Exit(1)
+		}
+
+		glog.Infof("%d notification(s) in spool %s", len(items), spool.Directory())
+
+		now := time.Now()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tATTEMPTS\tNEXT ATTEMPT\tSUBJECT\tLAST ERROR")
+		for _, item := range items {
+
+			next := item.NextAttempt.Format("2006-01-02 15:04:05")
+			if spool.IsExpired(item, now) {
+				next = "expired"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+				item.ID,
+				item.Created.Format("2006-01-02 15:04:05"),
+				item.Attempts,
+				next,
+				item.Subject,
+				item.LastError,
+			)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyListCmd)
+}
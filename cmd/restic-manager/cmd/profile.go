@@ -22,9 +22,6 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/i-am-david-fernandez/glog"
 	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
 	"github.com/spf13/cobra"
 )
@@ -32,27 +29,32 @@ import (
 // profileCmd represents the profile command
 var profileCmd = &cobra.Command{
 	Use:   "profile",
-	Short: "Print/display profile as loaded.",
-	Long:  `Print/display profile as loaded.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("profile called")
+	Short: "Inspect and manage profiles.",
+	Long: `Inspect and manage profiles.
 
-		for _, profile := range resticmanager.AppConfig.Profiles {
-			glog.Infof("Profile:\n%+v", profile)
-		}
-	},
+	Profiles may be listed, shown (with secret values redacted), scaffolded from the
+	application profile-defaults, and enabled or disabled. Enabling and disabling a profile
+	edits its file in place, preserving comments and layout.`,
 }
 
-func init() {
-	rootCmd.AddCommand(profileCmd)
+// findProfile returns the loaded profile (whether selected or not) with the specified name, or nil if there is none.
+func findProfile(name string) *resticmanager.ProfileConfiguration {
+
+	for _, profile := range resticmanager.AppConfig.Profiles {
+		if profile.Name() == name {
+			return profile
+		}
+	}
 
-	// Here you will define your flags and configuration settings.
+	for _, skipped := range resticmanager.AppConfig.SkippedProfiles {
+		if skipped.Profile.Name() == name {
+			return skipped.Profile
+		}
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// profileCmd.PersistentFlags().String("foo", "", "A help for foo")
+	return nil
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// profileCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+func init() {
+	rootCmd.AddCommand(profileCmd)
 }
@@ -0,0 +1,96 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	"github.com/spf13/cobra"
+)
+
+// setProfileActive enables or disables the named profiles, editing their files in place.
+func setProfileActive(names []string, active bool) {
+
+	failed := false
+	for _, name := range names {
+
+		profile := findProfile(name)
+		if profile == nil {
+			glog.Errorf("No profile named %v", name)
+			failed = true
+			continue
+		}
+
+		if profile.MatrixValue() != "" {
+			glog.Warningf("Profile %v is generated from a matrix; all profiles generated from %v are affected", name, profile.File())
+		}
+
+		if err := profile.SetActive(active); err != nil {
+			glog.Errorf("%v", err)
+			failed = true
+			continue
+		}
+
+		state := "disabled"
+		if active {
+			state = "enabled"
+		}
+		fmt.Printf("Profile %v %s (%v)\n", name, state, profile.File())
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// profileEnableCmd represents the profile enable command
+var profileEnableCmd = &cobra.Command{
+	Use:   "enable NAME...",
+	Short: "Enable profile(s).",
+	Long: `Enable the named profile(s), by setting "active: true" in each profile file.
+
+	The file is edited in place, preserving its comments and layout.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setProfileActive(args, true)
+	},
+}
+
+// profileDisableCmd represents the profile disable command
+var profileDisableCmd = &cobra.Command{
+	Use:   "disable NAME...",
+	Short: "Disable profile(s).",
+	Long: `Disable the named profile(s), by setting "active: false" in each profile file.
+
+	The file is edited in place, preserving its comments and layout.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setProfileActive(args, false)
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileEnableCmd)
+	profileCmd.AddCommand(profileDisableCmd)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var profileListFlags = struct {
+	all     bool
+	explain bool
+}{}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the selected profiles.",
+	Long: `List the selected profiles, along with the result of their most-recent run.
+
+	With --all, profiles excluded by the selection criteria (e.g., --filter-tags or --select)
+	are also listed. With --explain (which implies --all), the reason for the exclusion of
+	each profile is also shown.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		all := profileListFlags.all || profileListFlags.explain
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		header := "NAME\tACTIVE\tTAGS\tSOURCE\tREPO\tSCHEDULE\tLAST RESULT"
+		if profileListFlags.explain {
+			header += "\tSELECTED\tREASON"
+		}
+		fmt.Fprintln(w, header)
+
+		row := func(profile *resticmanager.ProfileConfiguration, selected bool, reason string) {
+
+			active := "no"
+			if profile.IsActive() {
+				active = "yes"
+			}
+
+			schedule := profile.Schedule()
+			if schedule == "" {
+				schedule = "-"
+			}
+
+			lastRun, result, _ := profileStatus(profile)
+			if lastRun != "never" {
+				result = fmt.Sprintf("%s (%s)", result, lastRun)
+			} else {
+				result = lastRun
+			}
+
+			line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				profile.Name(),
+				active,
+				strings.Join(profile.Tags(), ","),
+				profile.Source(),
+				resticmanager.RedactString(profile.Repository()),
+				schedule,
+				result,
+			)
+			if profileListFlags.explain {
+				selection := "no"
+				if selected {
+					selection = "yes"
+				}
+				line += fmt.Sprintf("\t%s\t%s", selection, reason)
+			}
+			fmt.Fprintln(w, line)
+		}
+
+		for _, profile := range resticmanager.AppConfig.Profiles {
+			row(profile, true, "")
+		}
+
+		if all {
+			for _, skipped := range resticmanager.AppConfig.SkippedProfiles {
+				row(skipped.Profile, false, skipped.Reason)
+			}
+		}
+
+		w.Flush()
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+
+	profileListCmd.Flags().BoolVar(&profileListFlags.all, "all", false, "also list profiles excluded by the selection criteria")
+	profileListCmd.Flags().BoolVar(&profileListFlags.explain, "explain", false, "also list excluded profiles, with the reason for their exclusion")
+}
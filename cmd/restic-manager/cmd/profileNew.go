@@ -0,0 +1,100 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var profileNewFlags = struct {
+	source string
+	repo   string
+	tags   []string
+	output string
+	force  bool
+}{}
+
+// profileNewCmd represents the profile new command
+var profileNewCmd = &cobra.Command{
+	Use:   "new NAME",
+	Short: "Create a new profile from the application profile-defaults.",
+	Long: `Create a new (inactive) profile file, named NAME.yml within the profile directory
+	(or the current directory if --profile-dir is not specified), unless --output is specified.
+
+	The application profile-defaults are included in the new profile as comments, for reference.
+	The repository password must be set before the profile is enabled (see "profile enable").`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		name := args[0]
+
+		filename := profileNewFlags.output
+		if filename == "" {
+			directory := rootFlags.profileDir
+			if directory == "" {
+				directory = "."
+			}
+			filename = filepath.Join(directory, name+".yml")
+		}
+
+		if _, err := os.Stat(filename); err == nil && !profileNewFlags.force {
+			glog.Errorf("%v already exists (use --force to overwrite)", filename)
+			os.Exit(1)
+		}
+
+		if findProfile(name) != nil {
+			glog.Warningf("A profile named %v already exists", name)
+		}
+
+		content := resticmanager.ProfileScaffold(
+			name,
+			profileNewFlags.source,
+			profileNewFlags.repo,
+			profileNewFlags.tags,
+			resticmanager.AppConfig.GetProfileDefaults(),
+		)
+
+		if err := ioutil.WriteFile(filename, content, 0600); err != nil {
+			glog.Errorf("Could not write profile: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Created %v\n", filename)
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileNewCmd)
+
+	profileNewCmd.Flags().StringVar(&profileNewFlags.source, "source", "", "the source directory")
+	profileNewCmd.Flags().StringVar(&profileNewFlags.repo, "repo", "", "the repository")
+	profileNewCmd.Flags().StringSliceVar(&profileNewFlags.tags, "tags", []string{}, "profile tags")
+	profileNewCmd.Flags().StringVarP(&profileNewFlags.output, "output", "o", "", "the profile file to create")
+	profileNewCmd.Flags().BoolVar(&profileNewFlags.force, "force", false, "overwrite an existing file")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var profileShowFlags struct {
+	effective bool
+	annotate  bool
+}
+
+// profileShowCmd represents the profile show command
+var profileShowCmd = &cobra.Command{
+	Use:   "show [NAME...]",
+	Short: "Show the configuration of the named or selected profile(s).",
+	Long: `Show the configuration of the named profile(s) or, if none are named, the selected profile(s).
+
+	With --effective (the default), the configuration is shown as used, i.e., with the profile
+	merged onto the application profile-defaults. Otherwise, only the content of the profile
+	file itself is shown. With --annotate, each (effective) value is annotated with its origin.
+	Secret values (e.g., the repository password) are redacted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		profiles := resticmanager.AppConfig.Profiles
+		if len(args) > 0 {
+			profiles = make([]*resticmanager.ProfileConfiguration, 0, len(args))
+			for _, name := range args {
+				profile := findProfile(name)
+				if profile == nil {
+					glog.Errorf("No profile named %v", name)
+					os.Exit(1)
+				}
+				profiles = append(profiles, profile)
+			}
+		}
+
+		for _, profile := range profiles {
+
+			fmt.Printf("# Profile %s (%s)\n", profile.Name(), profile.File())
+
+			switch {
+			case !profileShowFlags.effective:
+				fmt.Println(profile.RawString())
+			case profileShowFlags.annotate:
+				fmt.Println(profile.AnnotatedString())
+			default:
+				fmt.Println(profile.String())
+			}
+		}
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileShowCmd)
+
+	profileShowCmd.Flags().BoolVar(&profileShowFlags.effective, "effective", true, "show the effective configuration (i.e., including defaults)")
+	profileShowCmd.Flags().BoolVar(&profileShowFlags.annotate, "annotate", false, "annotate each effective value with its origin")
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+)
+
+// emailCase describes one of the set of emails sent for a profile, each to an
+// independent recipient list and with an independent log level filter.
+type emailCase struct {
+	name       string
+	recipients []string
+	level      glog.LogLevel
+	thresholds map[glog.LogLevel]int
+}
+
+// emailCases returns the set of emails to be sent for a profile.
+func emailCases(profile *resticmanager.ProfileConfiguration) []emailCase {
+
+	return []emailCase{
+		{
+			// Messages to application-configured recipients
+			"application",
+			resticmanager.AppConfig.EmailRecipients(),
+			resticmanager.AppConfig.EmailLogLevel(),
+			resticmanager.AppConfig.EmailThresholds(),
+		},
+		{
+			// Messages to profile-configured recipients
+			"profile",
+			profile.EmailRecipients(),
+			profile.EmailLogLevel(),
+			profile.EmailThresholds(),
+		},
+	}
+}
+
+// isTriggered returns true if the log summary exceeds the case thresholds (or
+// if there are no thresholds).
+func (c emailCase) isTriggered(summary []*glog.RecordSummary) bool {
+
+	if len(c.thresholds) == 0 {
+		return true
+	}
+
+	// We have some configured thresholds.
+	// Assume we should _not_ proceed (to mail)
+	// unless one or more thresholds are exceeded
+	for _, bin := range summary {
+		if threshold, ok := c.thresholds[bin.Level]; ok {
+			if bin.Count >= threshold {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// newReportMessage composes an email reporting on a processed profile.
+// The report log records should already be filtered to the case log level.
+func newReportMessage(profile *resticmanager.ProfileConfiguration, report *resticmanager.Report, c emailCase, context string, output []byte) *resticmanager.MailMessage {
+
+	message := resticmanager.NewMailMessage()
+	message.Sender = resticmanager.AppConfig.EmailSender()
+	message.AddRecipients(c.recipients...)
+	message.SetContext(context)
+
+	report.Preamble = fmt.Sprintf("Note: only log messages at or above level %s are displayed.", c.level)
+
+	message.AddLayeredTemplatedContent(resticmanager.AppConfig.EmailTemplates(profile), report)
+	if layers := resticmanager.AppConfig.EmailTextTemplates(profile); len(layers) > 0 {
+		message.AddLayeredTemplatedTextContent(layers, report)
+	}
+
+	if logFilename := profile.LogFile(); profile.EmailAttachLog() && (!rootFlags.noFileLogging) && (logFilename != "") {
+		if err := message.AttachFile(logFilename, profile.EmailCompressAbove()); err != nil {
+			glog.Warningf("Could not attach log file: %v", err)
+		}
+	}
+
+	if profile.EmailAttachOutput() && output != nil {
+		message.Attach(fmt.Sprintf("%s.restic-output.txt", profile.Name()), output, profile.EmailCompressAbove())
+	}
+
+	return message
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// retentionPreviewCmd represents the retention preview command
+var retentionPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Preview the application of retention policies.",
+	Long: `Preview the application of the retention policies of each (selected) profile.
+
+	For each snapshot, the keep/remove decision is shown, along with the rules that keep it.
+	Expired pins are not honoured, exactly as when the retention policies are applied.
+	Whether the application would be refused, by the retention guard (retention.max-remove-fraction
+	and retention.protect-newer-than) or because it would remove a pinned snapshot, is also shown. No snapshots are removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		failed := false
+
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			restic := resticmanager.NewRestic(resticmanager.AppConfig)
+
+			plan, err := restic.RetentionPlan(profile)
+			if err != nil {
+				glog.Errorf("%s: %v", profile.Name(), err)
+				failed = true
+				continue
+			}
+
+			fmt.Printf("# Profile %s (%s)\n", profile.Name(), resticmanager.RedactString(profile.Repository()))
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tID\tHOST\tPATHS\tTAGS\tDECISION\tREASON")
+			for _, decision := range plan.Decisions {
+
+				outcome := "remove"
+				if decision.Keep {
+					outcome = "keep"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					decision.Snapshot.Time.Format("2006-01-02 15:04:05"),
+					decision.Snapshot.ShortID,
+					decision.Snapshot.Hostname,
+					strings.Join(decision.Snapshot.Paths, ","),
+					strings.Join(decision.Snapshot.Tags, ","),
+					outcome,
+					strings.Join(decision.Reasons, ", "),
+				)
+			}
+			w.Flush()
+
+			removed := len(plan.Removed())
+			fmt.Printf("%d kept, %d removed.\n", len(plan.Decisions)-removed, removed)
+			for _, pin := range plan.ExpiredPins {
+				fmt.Printf("Pin on snapshot %s expired on %s; it is not honoured, and would be removed.\n", pin.Snapshot.ShortID, pin.Expiry())
+			}
+			if err := profile.CheckRetentionPlan(plan, time.Now()); err != nil {
+				fmt.Printf("Application would be refused: %v\n", err)
+			}
+			fmt.Println()
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	retentionCmd.AddCommand(retentionPreviewCmd)
+}
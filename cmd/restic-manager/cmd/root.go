@@ -32,12 +32,32 @@ import (
 var rootFlags struct {
 	dryrun        bool
 	appConfigFile string
+	environment   string
+	overrides     []string
 	logLevel      string
 	profileDir    string
 	profileFiles  []string
 	profileFilter resticmanager.ProfileFilter
-	noFileLogging bool
-	noEmail       bool
+	// selectExpression is parsed into profileFilter.Select
+	selectExpression string
+	noFileLogging    bool
+	noEmail          bool
+}
+
+// noMatrixCommands annotates commands (along with their subcommands) that do
+// not act on profiles, and so for which profile matrix commands are not run.
+const noMatrixCommands = "no-matrix-commands"
+
+// actsOnProfiles returns true unless the command (or a parent) is annotated with noMatrixCommands.
+func actsOnProfiles(cmd *cobra.Command) bool {
+
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[noMatrixCommands]; ok {
+			return false
+		}
+	}
+
+	return true
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -55,27 +75,63 @@ var rootCmd = &cobra.Command{
 
 		//glog.Debugf("AppConfig:\n%v\n", resticmanager.AppConfig)
 
+		var findErrors []error
+
 		if rootFlags.profileDir != "" {
 			// Find and add profile files from the specified directory
 			glog.Infof("Searching for profiles in %v", rootFlags.profileDir)
 
-			rootFlags.profileFiles = append(
-				rootFlags.profileFiles,
-				resticmanager.FindProfiles(rootFlags.profileDir)...,
-			)
+			var found []string
+			found, findErrors = resticmanager.FindProfiles(rootFlags.profileDir)
+
+			rootFlags.profileFiles = append(rootFlags.profileFiles, found...)
 		}
 
 		glog.Debugf("Specified and discovered profile files:\n%v\n", rootFlags.profileFiles)
 
-		// Load all required profiles, subject to filter criteria
-		resticmanager.AppConfig.Profiles = resticmanager.LoadProfiles(
+		if rootFlags.selectExpression != "" {
+			expression, err := resticmanager.ParseSelectExpression(rootFlags.selectExpression)
+			if err != nil {
+				glog.Criticalf("%v", err)
+				os.Exit(1)
+			}
+			rootFlags.profileFilter.Select = expression
+		}
+
+		// Load all profiles and select those matching the filter criteria
+		profiles, loadErrors := resticmanager.LoadAllProfiles(
 			rootFlags.profileFiles,
-			rootFlags.profileFilter,
 			resticmanager.AppConfig.GetProfileDefaults(),
+			actsOnProfiles(cmd),
+		)
+
+		resticmanager.AppConfig.LoadErrors = loadErrors
+		resticmanager.AppConfig.Profiles, resticmanager.AppConfig.SkippedProfiles = resticmanager.FilterProfiles(
+			profiles,
+			rootFlags.profileFilter,
 		)
 
+		resticmanager.AppConfig.LoadErrors = append(findErrors, resticmanager.AppConfig.LoadErrors...)
+
+		for _, err := range resticmanager.AppConfig.LoadErrors {
+			glog.Errorf("%v", err)
+		}
+
 		if len(resticmanager.AppConfig.Profiles) == 0 {
-			glog.Warningf("No profiles loaded!")
+			// Profiles intended for other hosts are expected (e.g., in a shared
+			// configuration tree) and so do not warrant a warning.
+			otherHost := 0
+			for _, skipped := range resticmanager.AppConfig.SkippedProfiles {
+				if skipped.OtherHost {
+					otherHost++
+				}
+			}
+
+			if otherHost > 0 && otherHost == len(resticmanager.AppConfig.SkippedProfiles) {
+				glog.Infof("No profiles are intended for this host.")
+			} else {
+				glog.Warningf("No profiles loaded!")
+			}
 		}
 
 		//glog.Debugf("Active profiles:\n", resticmanager.AppConfig.Profiles)
@@ -104,14 +160,20 @@ func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
-	rootCmd.PersistentFlags().StringVar(&rootFlags.appConfigFile, "config", "", "config file (default is $HOME/.restic-manager.yaml)")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.appConfigFile, "config", "", "config file, overlaid on the system (/etc/restic-manager/config.yaml), user ($XDG_CONFIG_HOME/restic-manager/config.yaml or $HOME/.restic-manager.yaml) and project (./.restic-manager.yaml) config")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.environment, "env", "", "overlay the named environment (from the config environments) onto the config")
+	rootCmd.PersistentFlags().StringArrayVar(&rootFlags.overrides, "set", make([]string, 0), "override a config value, e.g., --set email.smtp.port=25 (repeatable)")
 	rootCmd.PersistentFlags().StringVar(&rootFlags.profileDir, "profile-dir", "", "profile directory")
 	rootCmd.PersistentFlags().StringArrayVar(&rootFlags.profileFiles, "profile", make([]string, 0), "profile file")
 
 	// Profile selection filter flags
+	rootCmd.PersistentFlags().StringVar(&rootFlags.profileFilter.Host, "host", "", "select profiles intended for the specified host, rather than the local host (e.g., for testing)")
 	rootCmd.PersistentFlags().BoolVar(&rootFlags.profileFilter.OnlyActive, "filter-active", true, "select only active profiles")
-	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Names, "filter-names", make([]string, 0), "select only profiles with one of the specified names")
-	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Tags, "filter-tags", make([]string, 0), "select only profiles with all specified tags")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Names, "filter-names", make([]string, 0), "select only profiles with a name matching one of the specified (glob) patterns")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Tags, "filter-tags", make([]string, 0), "select only profiles with all specified tags (\"!tag\" excludes a tag, \"a|b\" requires any of a group)")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Sources, "filter-source", make([]string, 0), "select only profiles with a source within one of the specified paths")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.profileFilter.Repositories, "filter-repo", make([]string, 0), "select only profiles with a repository within one of the specified paths")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.selectExpression, "select", "", "select only profiles satisfying an expression, e.g., 'tag:nightly and not name:scratch-*'")
 
 	// Console logging level
 	rootCmd.PersistentFlags().StringVar(&rootFlags.logLevel, "log-level", "info", "console logging level")
@@ -134,10 +196,18 @@ func initConfig() {
 	const logNameConsole = "console"
 	level, _ := glog.NewLogLevel(rootFlags.logLevel)
 	glog.ClearBackends()
-	glog.SetBackend(logNameConsole, glog.NewWriterBackend(os.Stderr, "", level, ""))
+	glog.SetBackend(logNameConsole, resticmanager.NewRedactingBackend(glog.NewWriterBackend(os.Stderr, "", level, "")))
 
 	// Load config from file
-	resticmanager.AppConfig.Load(rootFlags.appConfigFile)
+	err := resticmanager.AppConfig.Load(resticmanager.AppConfigOptions{
+		File:        rootFlags.appConfigFile,
+		Environment: rootFlags.environment,
+		Overrides:   rootFlags.overrides,
+	})
+	if err != nil {
+		glog.Criticalf("%v", err)
+		os.Exit(1)
+	}
 	resticmanager.AppConfig.DryRun = rootFlags.dryrun
 
 	// Add file logging if required
@@ -145,13 +215,13 @@ func initConfig() {
 		const logNameFile = "appfile"
 
 		glog.SetBackend(logNameFile,
-			glog.NewFileBackend(
+			resticmanager.RedactFileBackend(glog.NewFileBackend(
 				logConfig.Filename,
 				logConfig.Append,
 				"",
 				logConfig.Level,
 				"", // Use default logging format
-			))
+			)))
 
 	}
 
@@ -47,6 +47,9 @@ var sanityCmd = &cobra.Command{
 			if !profile.SourceIsPresent() {
 				glog.Errorf("  Source %v is not present.", profile.Source())
 				errors++
+			} else if err := profile.Preflight(); err != nil {
+				glog.Errorf("  %v", err)
+				errors++
 			}
 
 			if errors > 0 {
@@ -14,11 +14,11 @@ func simpleCommand(resticFunction func(*resticmanager.ProfileConfiguration) (str
 
 		// Configure session logging (for subsequent e-mailing)
 		sessionBackend := glog.NewListBackend("", glog.Debug)
-		glog.SetBackend(logNameSession, sessionBackend)
+		glog.SetBackend(logNameSession, resticmanager.NewRedactingBackend(sessionBackend))
 
 		// Configure profile logging (to file)
 		if logFilename := profile.LogFile(); (!rootFlags.noFileLogging) && (logFilename != "") {
-			glog.SetBackend(logNameProfile, glog.NewFileBackend(logFilename, profile.LogFileAppend(), "", profile.LogFileLevel(), ""))
+			glog.SetBackend(logNameProfile, resticmanager.RedactFileBackend(glog.NewFileBackend(logFilename, profile.LogFileAppend(), "", profile.LogFileLevel(), "")))
 		}
 
 		glog.Infof("Processing profile %v", profile.Name())
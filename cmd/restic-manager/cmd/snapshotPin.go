@@ -0,0 +1,132 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+var snapshotPinFlags struct {
+	until string
+	note  string
+}
+
+// pinSnapshot pins (or, if pin is false, unpins) a snapshot in the repository of the named profile.
+func pinSnapshot(profileName string, id string, pin bool) error {
+
+	profile := findProfile(profileName)
+	if profile == nil {
+		return fmt.Errorf("No profile named %v", profileName)
+	}
+
+	var until time.Time
+	if pin && snapshotPinFlags.until != "" {
+		var err error
+		if until, err = time.ParseInLocation(resticmanager.PinDateFormat, snapshotPinFlags.until, time.Local); err != nil {
+			return fmt.Errorf("Invalid expiry date %q (expected YYYY-MM-DD)", snapshotPinFlags.until)
+		}
+	}
+
+	restic := resticmanager.NewRestic(resticmanager.AppConfig)
+
+	snapshot, err := restic.FindSnapshot(profile, id)
+	if err != nil {
+		return err
+	}
+
+	if pin {
+		err = restic.Pin(profile, *snapshot, until, snapshotPinFlags.note)
+	} else {
+		err = restic.Unpin(profile, *snapshot)
+	}
+	if err != nil {
+		return err
+	}
+
+	if pin {
+		expiry := resticmanager.Pin{Until: until}.Expiry()
+		fmt.Printf("Snapshot %s pinned (until %s)\n", snapshot.ShortID, expiry)
+	} else {
+		fmt.Printf("Snapshot %s unpinned\n", snapshot.ShortID)
+	}
+
+	// Refresh the recorded pins (as shown by "status")
+	if !resticmanager.AppConfig.DryRun {
+		if pins, err := restic.Pins(profile); err != nil {
+			glog.Warningf("%v", err)
+		} else if err := resticmanager.AppConfig.SavePins(profile.Name(), pins); err != nil {
+			glog.Warningf("Could not save pins: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// snapshotPinCmd represents the snapshot pin command
+var snapshotPinCmd = &cobra.Command{
+	Use:   "pin PROFILE SNAPSHOT",
+	Short: "Pin a snapshot.",
+	Long: `Pin a snapshot (given by ID, or "latest") in the repository of the named profile.
+	"latest" is the most-recent snapshot of the profile itself (i.e., of its hostname and source),
+	even if other hosts or sources share the repository.
+
+	A pinned snapshot is always kept by retention policy application, until its (optional) expiry
+	date, after which it is unpinned. The pin is recorded as restic tags ("pinned", along with
+	"pinned-until=DATE" and "pinned-note=NOTE"), so it is visible to restic itself. Pinning an
+	already-pinned snapshot replaces its expiry date and note.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pinSnapshot(args[0], args[1], true); err != nil {
+			glog.Errorf("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// snapshotUnpinCmd represents the snapshot unpin command
+var snapshotUnpinCmd = &cobra.Command{
+	Use:   "unpin PROFILE SNAPSHOT",
+	Short: "Unpin a snapshot.",
+	Long: `Unpin a snapshot (given by ID, or "latest") in the repository of the named profile,
+	so that it is subject to retention policy application once again.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pinSnapshot(args[0], args[1], false); err != nil {
+			glog.Errorf("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotPinCmd)
+	snapshotCmd.AddCommand(snapshotUnpinCmd)
+
+	snapshotPinCmd.Flags().StringVar(&snapshotPinFlags.until, "until", "", "Expiry date (YYYY-MM-DD) of the pin (default: never)")
+	snapshotPinCmd.Flags().StringVar(&snapshotPinFlags.note, "note", "", "Note recorded with the pin (e.g., its reason)")
+}
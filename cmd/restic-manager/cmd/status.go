@@ -0,0 +1,200 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// profileStatus returns a summary of the most-recent run of a profile.
+func profileStatus(profile *resticmanager.ProfileConfiguration) (string, string, string) {
+
+	report, err := resticmanager.AppConfig.LoadRunRecord(profile.Name())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("%v", err)
+		}
+		return "never", "-", "-"
+	}
+
+	result := "succeeded"
+	if !report.Succeeded() {
+		result = "failed"
+	}
+
+	return report.Start.Format("2006-01-02 15:04:05"), result, resticmanager.HumanDuration(report.Duration)
+}
+
+// formatTime formats a time for display ("never" if it is zero).
+func formatTime(when time.Time) string {
+
+	if when.IsZero() {
+		return "never"
+	}
+
+	return when.Format("2006-01-02 15:04:05")
+}
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of each (selected) profile.",
+	Long: `Show the status of each (selected) profile, including the outcome of its most-recent run,
+	its pinned snapshots (as most-recently retrieved from the repository), its accepted repositories
+	(e.g., rotating disks; see repository-identity) and when each was last backed up and, per
+	repository (by ID, where known), when each part of the repository data was last verified (see the profile check options).`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSOURCE\tREPOSITORY\tLAST RUN\tRESULT\tDURATION")
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			lastRun, result, duration := profileStatus(profile)
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				profile.Name(),
+				profile.Source(),
+				resticmanager.RedactString(profile.Repository()),
+				lastRun,
+				result,
+				duration,
+			)
+		}
+		w.Flush()
+
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			pins, err := resticmanager.AppConfig.LoadPins(profile.Name())
+			if err != nil {
+				glog.Warningf("%v", err)
+				continue
+			}
+			if len(pins) == 0 {
+				continue
+			}
+
+			fmt.Printf("\n# Pinned snapshots of %s\n", profile.Name())
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tTIME\tPINNED UNTIL\tNOTE")
+			for _, pin := range pins {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+					pin.Snapshot.ShortID,
+					pin.Snapshot.Time.Format("2006-01-02 15:04:05"),
+					pin.Expiry(),
+					pin.Note,
+				)
+			}
+			w.Flush()
+		}
+
+		now := time.Now()
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			identity, err := resticmanager.AppConfig.LoadRepositoryIdentity(profile.Name())
+			if err != nil {
+				glog.Warningf("%v", err)
+				continue
+			}
+			members := identity.Rotation(profile.RepositoryIDs(), profile.RotationOverdueAfter(), now)
+			if len(members) == 0 {
+				continue
+			}
+
+			fmt.Printf("\n# Repositories of %s\n", profile.Name())
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "REPOSITORY ID\tLAST BACKUP\tOVERDUE")
+			for _, member := range members {
+				overdue := ""
+				if member.Overdue {
+					overdue = "overdue"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", member.ID, formatTime(member.LastBackup), overdue)
+			}
+			w.Flush()
+		}
+
+		// Check state is recorded per repository ID (e.g., per rotating disk) where
+		// known, otherwise per repository location
+		states := make([]*resticmanager.CheckState, 0)
+		seen := make(map[string]bool)
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			ids := append([]string{}, profile.RepositoryIDs()...)
+			if identity, err := resticmanager.AppConfig.LoadRepositoryIdentity(profile.Name()); err == nil {
+				ids = append(append([]string{}, identity.Members...), ids...)
+			}
+			ids = append(ids, "")
+
+			for _, id := range ids {
+				key := id
+				if id == "" {
+					key = "location:" + profile.Repository()
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				state, err := resticmanager.AppConfig.LoadCheckState(id, profile.Repository())
+				if err != nil {
+					glog.Warningf("%v", err)
+					continue
+				}
+				if !state.LastCheck.IsZero() || len(state.Parts) > 0 {
+					states = append(states, state)
+				}
+			}
+		}
+
+		for _, state := range states {
+
+			if state.ID != "" {
+				fmt.Printf("\n# Verification of %s (repository ID %s)\n", state.Repository, state.ID)
+			} else {
+				fmt.Printf("\n# Verification of %s\n", state.Repository)
+			}
+			fmt.Printf("Last check: %s\n", formatTime(state.LastCheck))
+			if len(state.Parts) == 0 {
+				continue
+			}
+			fmt.Printf("Fully read since: %s\n", formatTime(state.OldestRead()))
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PART\tLAST READ")
+			for i, when := range state.Parts {
+				fmt.Fprintf(w, "%d/%d\t%s\n", i+1, len(state.Parts), formatTime(when))
+			}
+			w.Flush()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2019 David Fernandez <i.am.david.fernandez@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/i-am-david-fernandez/glog"
+	resticmanager "github.com/i-am-david-fernandez/restic-manager/internal"
+	"github.com/spf13/cobra"
+)
+
+// verifyRestoreCmd represents the verify restore command
+var verifyRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Perform a restore drill.",
+	Long: `Perform a restore drill for each (selected) profile.
+
+	The critical paths (verify-restore.paths) and a random sample of other files (verify-restore.sample)
+	are restored from the latest snapshot into a temporary directory beneath the application tempdir,
+	and compared (by size, permissions and SHA-256 content hash) with the snapshot and the live source.
+	Files changed since the snapshot are reported as expected drift; any other difference is an error.
+	The temporary directory is removed afterwards. The same drill is performed by the verify-restore
+	operation of the auto command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		failed := false
+
+		for _, profile := range resticmanager.AppConfig.Profiles {
+
+			restic := resticmanager.NewRestic(resticmanager.AppConfig)
+
+			verification, err := restic.VerifyRestore(profile)
+			if verification != nil {
+				fmt.Printf("%s: snapshot %s: %d matched, %d drifted, %d mismatched\n",
+					profile.Name(),
+					verification.Snapshot,
+					verification.Count(resticmanager.VerifyMatched),
+					verification.Count(resticmanager.VerifyDrifted),
+					verification.Count(resticmanager.VerifyMismatched),
+				)
+				for _, file := range verification.Mismatches() {
+					fmt.Printf("  %s: %s\n", file.Path, file.Detail)
+				}
+			}
+			if err != nil {
+				glog.Errorf("%s: %v", profile.Name(), err)
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	verifyCmd.AddCommand(verifyRestoreCmd)
+}
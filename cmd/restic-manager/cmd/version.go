@@ -30,9 +30,10 @@ import (
 
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Displays version information.",
-	Long:  `Displays version information.`,
+	Use:         "version",
+	Short:       "Displays version information.",
+	Long:        `Displays version information.`,
+	Annotations: map[string]string{noMatrixCommands: ""},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("version called")
 
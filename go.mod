@@ -7,10 +7,11 @@ require (
 	github.com/i-am-david-fernandez/glog v0.0.0-20190503121646-eb089695e16f
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/onsi/gomega v1.6.0
-	github.com/spf13/afero v1.2.2
+	github.com/shenwei356/go-logging v0.0.0-20171012171522-c6b9702d88ba
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.4.0
 	gopkg.in/yaml.v2 v2.2.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -22,7 +23,7 @@ require (
 	github.com/mattn/go-isatty v0.0.9 // indirect
 	github.com/mitchellh/mapstructure v1.1.2 // indirect
 	github.com/pelletier/go-toml v1.4.0 // indirect
-	github.com/shenwei356/go-logging v0.0.0-20171012171522-c6b9702d88ba // indirect
+	github.com/spf13/afero v1.2.2 // indirect
 	github.com/spf13/cast v1.3.0 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.3 // indirect
@@ -0,0 +1,372 @@
+package resticmanager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// AnomalyOptions encapsulates the settings of snapshot diff anomaly detection,
+// which compares each diff against a rolling baseline of those of recent runs.
+type AnomalyOptions struct {
+	Enabled bool `mapstructure:"enabled"`
+	// History is the number of recent runs forming the baseline.
+	History int `mapstructure:"history"`
+	// MinHistory is the number of runs required before outliers are reported.
+	MinHistory int `mapstructure:"min-history"`
+	// Sigma is the number of standard deviations above the baseline mean beyond which a value is an outlier.
+	Sigma float64 `mapstructure:"sigma"`
+	// FileDropFraction is the fraction (0-1) of files which, if lost since the previous run, is reported.
+	FileDropFraction float64 `mapstructure:"file-drop-fraction"`
+	// ExtensionChangeFraction and ExtensionChangeMin are the fraction (0-1) of, and
+	// minimum number of, changed files whose extension changed that is reported.
+	ExtensionChangeFraction float64 `mapstructure:"extension-change-fraction"`
+	ExtensionChangeMin      int     `mapstructure:"extension-change-min"`
+	// EntropySample is the number of changed files sampled for content entropy (0 disables sampling).
+	EntropySample int `mapstructure:"entropy-sample"`
+	// EntropyJump is the rise (in bits per byte) in mean sampled entropy above the baseline that is reported.
+	EntropyJump float64 `mapstructure:"entropy-jump"`
+}
+
+// DefaultAnomalyOptions returns the default anomaly detection settings.
+func DefaultAnomalyOptions() AnomalyOptions {
+
+	return AnomalyOptions{
+		Enabled:                 true,
+		History:                 20,
+		MinHistory:              5,
+		Sigma:                   3,
+		FileDropFraction:        0.1,
+		ExtensionChangeFraction: 0.5,
+		ExtensionChangeMin:      20,
+		EntropySample:           0,
+		EntropyJump:             1,
+	}
+}
+
+// DiffSample encapsulates the anomaly-relevant measures of a single snapshot diff.
+type DiffSample struct {
+	Time         time.Time
+	FilesChanged int
+	FilesRemoved int
+	BytesAdded   float64
+	// FileCount is the number of files in the new snapshot (0 if unknown).
+	FileCount int
+	// Entropy is the mean content entropy (bits per byte) of the sampled changed files (0 if not sampled).
+	Entropy float64
+}
+
+// AnomalyHistory encapsulates the diff samples of recent runs of a profile, oldest first.
+type AnomalyHistory struct {
+	Samples []DiffSample
+}
+
+// meanStdDev returns the mean and (population) standard deviation of a set of values.
+func meanStdDev(values []float64) (float64, float64) {
+
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// outlier returns a description of the value if it lies more than sigma
+// standard deviations above the baseline mean. The standard deviation is
+// taken to be at least 10% of the mean, and at least floor, so that steady
+// baselines do not flag minor variations.
+func outlier(name string, value float64, baseline []float64, sigma float64, floor float64, format func(float64) string) string {
+
+	mean, stdDev := meanStdDev(baseline)
+	stdDev = math.Max(stdDev, math.Max(0.1*mean, floor))
+
+	if value <= mean+sigma*stdDev {
+		return ""
+	}
+
+	return fmt.Sprintf("%s (%s) is %.1f standard deviations above the baseline mean (%s over %d runs)",
+		name, format(value), (value-mean)/stdDev, format(mean), len(baseline))
+}
+
+// Detect returns the anomalies of a diff sample, relative to the history.
+func (history *AnomalyHistory) Detect(sample DiffSample, options AnomalyOptions) []string {
+
+	anomalies := make([]string, 0)
+
+	count := func(value float64) string { return fmt.Sprintf("%.0f", value) }
+	bytes := func(value float64) string { return HumanBytes(value) }
+
+	baseline := history.Samples
+	if len(baseline) > options.History && options.History > 0 {
+		baseline = baseline[len(baseline)-options.History:]
+	}
+
+	if len(baseline) >= options.MinHistory {
+
+		changed := make([]float64, 0, len(baseline))
+		removed := make([]float64, 0, len(baseline))
+		added := make([]float64, 0, len(baseline))
+		for _, past := range baseline {
+			changed = append(changed, float64(past.FilesChanged))
+			removed = append(removed, float64(past.FilesRemoved))
+			added = append(added, past.BytesAdded)
+		}
+
+		for _, anomaly := range []string{
+			outlier("Files changed", float64(sample.FilesChanged), changed, options.Sigma, 10, count),
+			outlier("Files removed", float64(sample.FilesRemoved), removed, options.Sigma, 10, count),
+			outlier("Bytes added", sample.BytesAdded, added, options.Sigma, 1024*1024, bytes),
+		} {
+			if anomaly != "" {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+
+		if sample.Entropy > 0 {
+			entropies := make([]float64, 0, len(baseline))
+			for _, past := range baseline {
+				if past.Entropy > 0 {
+					entropies = append(entropies, past.Entropy)
+				}
+			}
+			if len(entropies) >= options.MinHistory {
+				if mean, _ := meanStdDev(entropies); sample.Entropy-mean > options.EntropyJump {
+					anomalies = append(anomalies, fmt.Sprintf("Mean content entropy of sampled changed files (%.2f bits/byte) is well above the baseline (%.2f bits/byte), suggesting encryption",
+						sample.Entropy, mean))
+				}
+			}
+		}
+	}
+
+	if len(history.Samples) > 0 && sample.FileCount > 0 {
+		previous := history.Samples[len(history.Samples)-1].FileCount
+		if previous > 0 && float64(previous-sample.FileCount) > options.FileDropFraction*float64(previous) {
+			anomalies = append(anomalies, fmt.Sprintf("File count dropped from %d to %d (%.0f%%) since the previous run",
+				previous, sample.FileCount, 100*float64(previous-sample.FileCount)/float64(previous)))
+		}
+	}
+
+	return anomalies
+}
+
+// Record appends a diff sample to the history, retaining only the most-recent samples.
+func (history *AnomalyHistory) Record(sample DiffSample, options AnomalyOptions) {
+
+	history.Samples = append(history.Samples, sample)
+
+	if options.History > 0 && len(history.Samples) > options.History {
+		history.Samples = history.Samples[len(history.Samples)-options.History:]
+	}
+}
+
+// DiffPaths encapsulates the paths listed by "restic diff".
+type DiffPaths struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// ParseDiffPaths parses the (file) paths listed by "restic diff", i.e., lines
+// such as "+    /home/user/report.odt.locked" (added), "-    /home/user/report.odt"
+// (removed) and "M    /home/user/notes.txt" (modified).
+func ParseDiffPaths(diffText string) DiffPaths {
+
+	paths := DiffPaths{}
+
+	scanner := bufio.NewScanner(strings.NewReader(diffText))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 2 || (line[1] != ' ' && line[1] != '\t') {
+			continue
+		}
+
+		path := strings.TrimSpace(line[1:])
+		if path == "" || strings.HasSuffix(path, "/") {
+			// Directories are of no interest
+			continue
+		}
+
+		switch line[0] {
+		case '+':
+			paths.Added = append(paths.Added, path)
+		case '-':
+			paths.Removed = append(paths.Removed, path)
+		case 'M':
+			paths.Modified = append(paths.Modified, path)
+		}
+	}
+
+	return paths
+}
+
+// ExtensionChanges returns the number of removed files replaced by an added
+// file differing only in its extension, e.g., report.odt replaced by
+// report.odt.locked or by report.enc.
+func (paths DiffPaths) ExtensionChanges() int {
+
+	removed := make(map[string]bool)
+	removedStems := make(map[string]int)
+	for _, path := range paths.Removed {
+		removed[path] = true
+		removedStems[strings.TrimSuffix(path, filepath.Ext(path))]++
+	}
+
+	changes := 0
+	for _, path := range paths.Added {
+		ext := filepath.Ext(path)
+		if ext == "" {
+			continue
+		}
+		stem := strings.TrimSuffix(path, ext)
+		if removed[stem] {
+			// An appended extension
+			changes++
+		} else if removedStems[stem] > 0 && !removed[path] {
+			// A replaced extension
+			removedStems[stem]--
+			changes++
+		}
+	}
+
+	return changes
+}
+
+// contentEntropy returns the Shannon entropy (in bits per byte, 0-8) of (up to) the first limit bytes of a file.
+func contentEntropy(filename string, limit int64) (float64, error) {
+
+	handle, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Close()
+
+	var counts [256]int64
+	total := int64(0)
+
+	buffer := make([]byte, 32*1024)
+	reader := io.LimitReader(handle, limit)
+	for {
+		n, err := reader.Read(buffer)
+		for _, b := range buffer[:n] {
+			counts[b]++
+		}
+		total += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return entropy, nil
+}
+
+// sampleEntropy returns the mean content entropy of a random sample of (up to
+// count) files, as currently present in the live source (0 if none could be read).
+func sampleEntropy(paths []string, count int, random *rand.Rand) float64 {
+
+	candidates := append([]string{}, paths...)
+	random.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	sum := 0.0
+	sampled := 0
+	for _, path := range candidates {
+		if sampled >= count {
+			break
+		}
+		entropy, err := contentEntropy(path, 64*1024)
+		if err != nil {
+			glog.Debugf("Could not sample entropy of %s: %v", path, err)
+			continue
+		}
+		sum += entropy
+		sampled++
+	}
+
+	if sampled == 0 {
+		return 0
+	}
+
+	return sum / float64(sampled)
+}
+
+// DetectAnomalies compares a snapshot diff (and, if known, the number of files
+// in the new snapshot) against the profile anomaly history (see AnomalyOptions),
+// logging a warning for each anomaly, then records the diff in the history.
+// The anomalies are returned.
+func DetectAnomalies(profile *ProfileConfiguration, diff *SnapshotDiff, fileCount int) []string {
+
+	options := profile.AnomalyOptions()
+	if !options.Enabled || diff == nil {
+		return nil
+	}
+
+	history := &AnomalyHistory{}
+	if err := AppConfig.loadState("anomalies", profile.Name(), history); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("%v", err)
+	}
+
+	sample := DiffSample{
+		Time:         time.Now(),
+		FilesChanged: diff.FilesChanged,
+		FilesRemoved: diff.FilesRemoved,
+		BytesAdded:   diff.BytesAdded,
+		FileCount:    fileCount,
+	}
+
+	paths := ParseDiffPaths(diff.Report)
+
+	if options.EntropySample > 0 {
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		sample.Entropy = sampleEntropy(append(paths.Added, paths.Modified...), options.EntropySample, random)
+	}
+
+	anomalies := history.Detect(sample, options)
+
+	if changes := paths.ExtensionChanges(); changes >= options.ExtensionChangeMin {
+		if touched := diff.FilesChanged + diff.FilesRemoved; touched > 0 && float64(changes) > options.ExtensionChangeFraction*float64(touched) {
+			anomalies = append(anomalies, fmt.Sprintf("%d of %d changed or removed files were replaced by files with a different extension", changes, touched))
+		}
+	}
+
+	for _, anomaly := range anomalies {
+		glog.Warningf("Anomaly: %s", anomaly)
+	}
+
+	history.Record(sample, options)
+	if !AppConfig.DryRun {
+		if err := AppConfig.saveState("anomalies", profile.Name(), history); err != nil {
+			glog.Warningf("Could not save anomaly history: %v", err)
+		}
+	}
+
+	return anomalies
+}
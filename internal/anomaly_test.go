@@ -0,0 +1,97 @@
+package resticmanager
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestAnomalyDetection(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	options := DefaultAnomalyOptions()
+	options.History = 6
+
+	history := &AnomalyHistory{}
+	for i := 0; i < 8; i++ {
+		history.Record(DiffSample{FilesChanged: 40 + i%3, FilesRemoved: 2, BytesAdded: 50 * 1024 * 1024, FileCount: 10000}, options)
+	}
+	g.Expect(history.Samples).Should(gomega.HaveLen(6))
+
+	// Typical runs raise nothing
+	g.Expect(history.Detect(DiffSample{FilesChanged: 44, FilesRemoved: 5, BytesAdded: 60 * 1024 * 1024, FileCount: 9990}, options)).Should(gomega.BeEmpty())
+
+	// Outliers and a sudden file-count drop
+	anomalies := history.Detect(DiffSample{FilesChanged: 900, FilesRemoved: 2, BytesAdded: 50 * 1024 * 1024, FileCount: 7000}, options)
+	g.Expect(anomalies).Should(gomega.HaveLen(2))
+	g.Expect(anomalies[0]).Should(gomega.HavePrefix("Files changed (900) is"))
+	g.Expect(anomalies[1]).Should(gomega.Equal("File count dropped from 10000 to 7000 (30%) since the previous run"))
+
+	// Outliers require a sufficient history
+	options.MinHistory = 10
+	g.Expect(history.Detect(DiffSample{FilesChanged: 900, FileCount: 10000}, options)).Should(gomega.BeEmpty())
+	options.MinHistory = 5
+
+	// A jump in sampled entropy
+	for i := range history.Samples {
+		history.Samples[i].Entropy = 4.5
+	}
+	g.Expect(history.Detect(DiffSample{FilesChanged: 40, Entropy: 5}, options)).Should(gomega.BeEmpty())
+	g.Expect(history.Detect(DiffSample{FilesChanged: 40, Entropy: 7.9}, options)).Should(gomega.ConsistOf(gomega.ContainSubstring("suggesting encryption")))
+}
+
+func TestDiffPaths(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	paths := ParseDiffPaths(`comparing snapshot 1a2b3c4d to 5e6f7a8b:
+
++    /home/docs/
++    /home/docs/a.odt.locked
+-    /home/docs/a.odt
++    /home/docs/b.enc
+-    /home/docs/b.docx
+M    /home/docs/c.txt
++    /home/docs/new.txt
+-    /home/docs/old.txt
+
+Files:           3 new,     3 removed,     1 changed
+`)
+
+	g.Expect(paths.Added).Should(gomega.Equal([]string{"/home/docs/a.odt.locked", "/home/docs/b.enc", "/home/docs/new.txt"}))
+	g.Expect(paths.Removed).Should(gomega.Equal([]string{"/home/docs/a.odt", "/home/docs/b.docx", "/home/docs/old.txt"}))
+	g.Expect(paths.Modified).Should(gomega.Equal([]string{"/home/docs/c.txt"}))
+	g.Expect(paths.ExtensionChanges()).Should(gomega.Equal(2))
+}
+
+func TestContentEntropy(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "entropy")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	text := filepath.Join(directory, "text")
+	g.Expect(ioutil.WriteFile(text, []byte(strings.Repeat("abcd", 1024)), 0600)).Should(gomega.Succeed())
+
+	random := make([]byte, 64*1024)
+	_, err = rand.Read(random)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	encrypted := filepath.Join(directory, "encrypted")
+	g.Expect(ioutil.WriteFile(encrypted, random, 0600)).Should(gomega.Succeed())
+
+	entropy, err := contentEntropy(text, 64*1024)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(entropy).Should(gomega.BeNumerically("~", 2, 0.001))
+
+	entropy, err = contentEntropy(encrypted, 64*1024)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(entropy).Should(gomega.BeNumerically(">", 7.9))
+}
@@ -1,7 +1,11 @@
 package resticmanager
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/i-am-david-fernandez/glog"
 	homedir "github.com/mitchellh/go-homedir"
@@ -12,8 +16,14 @@ import (
 // AppConfiguration encapsulates the global application configuration.
 type AppConfiguration struct {
 	viper    *viper.Viper
+	files    []string
+	origins  map[string]string
 	Profiles []*ProfileConfiguration
-	DryRun   bool
+	// SkippedProfiles holds the loaded profiles excluded by the profile filter.
+	SkippedProfiles []SkippedProfile
+	// LoadErrors holds the errors encountered while loading profiles.
+	LoadErrors []error
+	DryRun     bool
 }
 
 // AppConfig is the global, singleton application configuration object.
@@ -39,49 +49,151 @@ func NewAppConfiguration() *AppConfiguration {
 	}
 }
 
-// LoadAppConfiguration creates and returns a new AppConfiguration populated from a file.
+// LoadAppConfiguration creates and returns a new AppConfiguration populated from a file
+// (overlaid on the system, user and project configuration; see Load).
 func LoadAppConfiguration(filename string) *AppConfiguration {
 
 	config := NewAppConfiguration()
 
-	config.Load(filename)
+	if err := config.Load(AppConfigOptions{File: filename}); err != nil {
+		glog.Errorf("%v", err)
+	}
 
 	return config
 }
 
-// Load populates an existing AppConfig from a file.
-func (appConfig *AppConfiguration) Load(filename string) {
+// Load populates an existing AppConfig from its layered sources. In order of
+// increasing precedence, these are: the system configuration (config.* within
+// SystemConfigDir), the user configuration (config.* within
+// $XDG_CONFIG_HOME/restic-manager, then ~/.restic-manager.*), the project
+// configuration (.restic-manager.* within the working directory), the specified
+// file, the specified environment overlay, environment variables (prefixed with
+// EnvPrefix) and, finally, the specified overrides. Each file is merged onto its
+// predecessors, honouring merge directives (see mergeSettings).
+func (appConfig *AppConfiguration) Load(options AppConfigOptions) error {
+
+	settings := make(map[string]interface{})
+	appConfig.origins = make(map[string]string)
+	appConfig.files = make([]string, 0)
+
+	for _, filename := range appConfigLayers(options.File) {
+
+		layer, err := readSettings(filename)
+		if err != nil {
+			glog.Errorf("Could not load application configuration from %v: %v", filename, err)
+			continue
+		}
+
+		glog.Debugf("Using config file: %s", filename)
+
+		issues, err := ValidateSettingsFile(filename, AppSchema())
+		if err != nil {
+			glog.Debugf("Could not validate %v: %v", filename, err)
+		}
+		for _, issue := range issues {
+			glog.Warningf("Application configuration: %v", issue)
+		}
+
+		if err := mergeSettings(settings, layer, filename, appConfig.origins, ""); err != nil {
+			return fmt.Errorf("Could not merge %v: %v", filename, err)
+		}
+		appConfig.files = append(appConfig.files, filename)
+	}
+
+	if options.Environment != "" {
+		if err := applyEnvironment(settings, options.Environment, appConfig.origins); err != nil {
+			return err
+		}
+		glog.Debugf("Using environment: %s", options.Environment)
+	}
+
+	for _, assignment := range options.Overrides {
 
-	if filename != "" {
-		// Use config file from the flag.
-		appConfig.viper.SetConfigFile(filename)
-	} else {
-		// Find home directory.
-		home, err := homedir.Dir()
+		overlay, err := parseOverride(assignment)
 		if err != nil {
-			glog.Errorf("Error determining home directory: %v", err)
-			os.Exit(1)
+			return err
+		}
+
+		for _, issue := range AppSchema().ValidateSettings(overlay, OriginOverride) {
+			glog.Warningf("Application configuration: %v", issue)
+		}
+
+		if err := mergeSettings(settings, overlay, OriginOverride, appConfig.origins, ""); err != nil {
+			return fmt.Errorf("Could not apply override %q: %v", assignment, err)
+		}
+	}
+
+	// Only explicitly-prefixed environment variables are considered, so that
+	// unrelated variables (e.g., LOGGING) cannot leak into the configuration.
+	appConfig.viper.SetEnvPrefix(EnvPrefix)
+	appConfig.viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	appConfig.viper.AutomaticEnv()
+
+	registerSettingsSecrets(settings)
+	for _, variable := range os.Environ() {
+		if index := strings.Index(variable, "="); index > 0 && strings.HasPrefix(variable, EnvPrefix+"_") {
+			if isSecretKey(strings.ToLower(strings.Replace(variable[:index], "_", "-", -1))) {
+				RegisterSecret(variable[index+1:])
+			}
+		}
+	}
+
+	if err := appConfig.viper.MergeConfigMap(settings); err != nil {
+		return err
+	}
+
+	// Overridden values must take precedence over environment variables, so are
+	// (also) set explicitly, as viper ranks environment variables above configuration.
+	for key, value := range flattenSettings(settings, "", nil) {
+		if origin := appConfig.origins[key]; origin == OriginOverride || strings.HasSuffix(origin, " + "+OriginOverride) {
+			appConfig.viper.Set(key, value)
 		}
+	}
 
-		// Search config in home directory with name ".restic-manager" (without extension).
-		appConfig.viper.AddConfigPath(home)
-		appConfig.viper.SetConfigName(".restic-manager")
+	return nil
+}
+
+// layered returns a viper holding the fully-layered settings. Unlike the
+// configuration viper, values retrieved from it as a whole map (e.g., via
+// UnmarshalKey or Sub) reflect the environment variables and overrides of
+// their nested values, which otherwise are ignored or shadow the map.
+func (appConfig *AppConfiguration) layered() *viper.Viper {
+
+	v := viper.New()
+	if err := v.MergeConfigMap(appConfig.viper.AllSettings()); err != nil {
+		glog.Errorf("Could not layer application configuration: %v", err)
 	}
 
-	appConfig.viper.AutomaticEnv() // read in environment variables that match
+	return v
+}
+
+// ConfigFileUsed returns the highest-precedence application configuration file in use (if any).
+func (appConfig *AppConfiguration) ConfigFileUsed() string {
 
-	// If a config file is found, read it in.
-	if err := appConfig.viper.ReadInConfig(); err != nil {
-		glog.Errorf("Could not load application configuration from %v: %v", filename, err)
-	} else {
-		glog.Debugf("Using config file: %s", appConfig.viper.ConfigFileUsed())
+	if len(appConfig.files) == 0 {
+		return ""
 	}
+
+	return appConfig.files[len(appConfig.files)-1]
+}
+
+// ConfigFilesUsed returns all application configuration files in use, lowest precedence first.
+func (appConfig *AppConfiguration) ConfigFilesUsed() []string {
+
+	return appConfig.files
 }
 
-// String returns a string representation of the AppConfiguration
+// Origin returns the origin (i.e., the configuration file, environment overlay
+// or command-line override) of a (leaf) configuration value.
+func (appConfig *AppConfiguration) Origin(key string) string {
+
+	return appConfig.origins[key]
+}
+
+// String returns a string representation of the AppConfiguration, with secret values redacted.
 func (appConfig *AppConfiguration) String() string {
 
-	c := appConfig.viper.AllSettings()
+	c := redactSettings(appConfig.viper.AllSettings())
 
 	content, err := yaml.Marshal(c)
 	if err != nil {
@@ -97,7 +209,7 @@ func (appConfig *AppConfiguration) GetProfileDefaults() map[string]interface{} {
 	key := "profile-defaults"
 
 	if appConfig.viper.IsSet(key) {
-		return appConfig.viper.Sub(key).AllSettings()
+		return appConfig.layered().Sub(key).AllSettings()
 	}
 
 	return nil
@@ -127,6 +239,83 @@ func (appConfig *AppConfiguration) Tempdir() string {
 	return os.TempDir()
 }
 
+// StateDir returns the directory used for persistent application state.
+func (appConfig *AppConfiguration) StateDir() string {
+
+	key := "state-dir"
+
+	if appConfig.viper.IsSet(key) {
+		return appConfig.viper.GetString(key)
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		glog.Errorf("Error determining home directory: %v", err)
+		return filepath.Join(os.TempDir(), "restic-manager")
+	}
+
+	return filepath.Join(home, ".restic-manager.d")
+}
+
+// SpoolDir returns the directory used to spool outbound notifications.
+func (appConfig *AppConfiguration) SpoolDir() string {
+
+	key := "notify.spool-dir"
+
+	if appConfig.viper.IsSet(key) {
+		return appConfig.viper.GetString(key)
+	}
+
+	return filepath.Join(appConfig.StateDir(), "spool")
+}
+
+// NotifyMaxAge returns the age after which undelivered notifications are discarded.
+func (appConfig *AppConfiguration) NotifyMaxAge() time.Duration {
+
+	key := "notify.max-age"
+
+	if appConfig.viper.IsSet(key) {
+		return appConfig.viper.GetDuration(key)
+	}
+
+	return 72 * time.Hour
+}
+
+// NotifyRetryBackoff returns the initial delay between notification delivery attempts.
+func (appConfig *AppConfiguration) NotifyRetryBackoff() time.Duration {
+
+	key := "notify.retry-backoff"
+
+	if appConfig.viper.IsSet(key) {
+		return appConfig.viper.GetDuration(key)
+	}
+
+	return 5 * time.Minute
+}
+
+// NotifyMaxBackoff returns the maximum delay between notification delivery attempts.
+func (appConfig *AppConfiguration) NotifyMaxBackoff() time.Duration {
+
+	key := "notify.max-backoff"
+
+	if appConfig.viper.IsSet(key) {
+		return appConfig.viper.GetDuration(key)
+	}
+
+	return 6 * time.Hour
+}
+
+// NewSpool returns a new notification Spool as configured.
+func (appConfig *AppConfiguration) NewSpool() *Spool {
+
+	return NewSpool(
+		appConfig.SpoolDir(),
+		appConfig.NotifyMaxAge(),
+		appConfig.NotifyRetryBackoff(),
+		appConfig.NotifyMaxBackoff(),
+	)
+}
+
 type _LoggingConfig struct {
 	Filename string `mapstructure:"file"`
 	Level    glog.LogLevel
@@ -148,7 +337,7 @@ func (appConfig *AppConfiguration) LoggingConfig() *_LoggingConfig {
 
 	if appConfig.viper.IsSet(key) {
 		var r _RawLoggingConfig
-		if err := appConfig.viper.UnmarshalKey(key, &r); err != nil {
+		if err := appConfig.layered().UnmarshalKey(key, &r); err != nil {
 			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
 			return nil
 		}
@@ -254,7 +443,7 @@ func (appConfig *AppConfiguration) EmailThresholds() map[glog.LogLevel]int {
 	rawThresholds := make(map[string]int)
 
 	if appConfig.viper.IsSet(key) {
-		if err := appConfig.viper.UnmarshalKey(key, &rawThresholds); err != nil {
+		if err := appConfig.layered().UnmarshalKey(key, &rawThresholds); err != nil {
 			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
 			return thresholds
 		}
@@ -268,95 +457,90 @@ func (appConfig *AppConfiguration) EmailThresholds() map[glog.LogLevel]int {
 	return thresholds
 }
 
-// EmailTemplate returns the email template.
+// EmailTemplate returns the application-level email template, taken from
+// (in order of precedence) "email.template-file" (resolved relative to the
+// configuration file), "email.template" or the built-in default.
 func (appConfig *AppConfiguration) EmailTemplate() string {
 
-	key := "email.template"
+	if layer := appConfig.emailTemplateLayer("email.template-file", "email.template"); layer != "" {
+		return layer
+	}
 
-	if appConfig.viper.IsSet(key) {
-		return appConfig.viper.GetString(key)
+	return DefaultEmailTemplate
+}
+
+// emailTemplateLayer returns a configured template, read from file or given inline.
+func (appConfig *AppConfiguration) emailTemplateLayer(fileKey string, inlineKey string) string {
+
+	if appConfig.viper.IsSet(fileKey) {
+		// Relative template files are resolved against the file that specified them
+		base := appConfig.Origin(fileKey)
+		if !containsString(appConfig.files, base) {
+			base = appConfig.ConfigFileUsed()
+		}
+		content, err := readTemplateFile(appConfig.viper.GetString(fileKey), base)
+		if err != nil {
+			glog.Errorf("%v", err)
+		} else {
+			return content
+		}
 	}
 
-	return `
-	<html>
+	if appConfig.viper.IsSet(inlineKey) {
+		return appConfig.viper.GetString(inlineKey)
+	}
 
-	<head>
-		<style>
-			.code {
-				font-family: monospace;
-				white-space: pre;
-				vertical-align: baseline;
-				text-align: left;
-			}
-	
-			.debug {
-				color: darkgray;
-				display: table-row;
-			}
-			
-			.info {
-				color: steelblue;
-				display: table-row;
-			}
-			
-			.notice {
-				color: seagreen;
-				display: table-row;
-			}
-			
-			.warning {
-				color: orange;
-				display: table-row;
-			}
-			
-			.error {
-				color: darkred;
-				display: table-row;
-			}
-			
-			.critical {
-				color: darkorchid;
-				display: table-row;
-			}
+	return ""
+}
+
+// EmailTemplates returns the sequence of (html) email template layers for a
+// profile: the built-in default, then the application-level template and
+// finally any profile-level template. Later layers may replace the template
+// entirely or override individual blocks via {{define "block"}}...{{end}}.
+func (appConfig *AppConfiguration) EmailTemplates(profile *ProfileConfiguration) []string {
+
+	layers := []string{DefaultEmailTemplate}
+
+	if layer := appConfig.emailTemplateLayer("email.template-file", "email.template"); layer != "" {
+		layers = append(layers, layer)
+	}
+
+	if profile != nil {
+		if layer := profile.EmailTemplate(); layer != "" {
+			layers = append(layers, layer)
+		}
+	}
+
+	return layers
+}
+
+// EmailTextTemplates returns the sequence of plain-text email template layers
+// for a profile (application-level, then profile-level). If empty, no plain-text
+// content is sent.
+func (appConfig *AppConfiguration) EmailTextTemplates(profile *ProfileConfiguration) []string {
+
+	layers := make([]string, 0)
+
+	if layer := appConfig.EmailTextTemplate(); layer != "" {
+		layers = append(layers, layer)
+	}
+
+	if profile != nil {
+		if layer := profile.EmailTextTemplate(); layer != "" {
+			layers = append(layers, layer)
+		}
+	}
+
+	return layers
+}
+
+// EmailTextTemplate returns the (optional) application-level plain-text email
+// template, taken from "email.text-template-file" or "email.text-template".
+// If configured, emails are sent as multipart/alternative with both plain-text
+// and html content.
+func (appConfig *AppConfiguration) EmailTextTemplate() string {
 
-		</style>
-
-	</head>
-	
-	<body>
-
-	<div>{{.Preamble}}</div>
-
-	<h2>Log Summary</h2>
-	<table>
-        {{range .LogSummary}}
-		<tr class="code {{.Level}}">
-        	<th>Messages at level {{.Level}}</th>
-        	<td>{{.Count}}</td>
-		</tr>
-        {{end}}
-	</table>
-
-	<h2>Log Records</h2>
-	<table>
-	<tr>
-		<th>Time</th>
-		<th>Level</th>
-		<th>Message</th>
-	</tr>
-	{{range .LogRecords}}
-	<tr class="code {{.Level}}">
-		<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
-		<td>{{.Level}}</td>
-		<td>{{.Message}}</td>
-	</tr>
-	{{end}}
-	</table>
-
-	</body>
-
-	</html>
-	`
+	return appConfig.emailTemplateLayer("email.text-template-file", "email.text-template")
 }
 
 // NewMailer returns a new Mailer
@@ -366,7 +550,7 @@ func (appConfig *AppConfiguration) NewMailer() *Mailer {
 
 	if appConfig.viper.IsSet(key) {
 		mailer := NewMailer()
-		if err := appConfig.viper.UnmarshalKey(key, mailer); err != nil {
+		if err := appConfig.layered().UnmarshalKey(key, mailer); err != nil {
 			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
 			return nil
 		}
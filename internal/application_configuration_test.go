@@ -0,0 +1,106 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/onsi/gomega"
+)
+
+func TestAppConfigurationLayers(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "layers")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	write := func(filename string, content string) string {
+		filename = filepath.Join(directory, filename)
+		g.Expect(os.MkdirAll(filepath.Dir(filename), 0700)).Should(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+		return filename
+	}
+
+	system := write("etc/config.yml", `
+executable: /usr/bin/restic
+tempdir: /var/tmp
+email:
+  recipients: [ops@example.com]
+  smtp:
+    host: mail.example.com
+    port: 587
+`)
+	user := write("xdg/restic-manager/config.yaml", `
+tempdir: /home/user/tmp
+email:
+  recipients+: [user@example.com]
+environments:
+  staging:
+    tempdir: /staging/tmp
+`)
+	explicit := write("explicit.yml", `
+state-dir: /srv/state
+`)
+
+	defer func(dir string) { SystemConfigDir = dir }(SystemConfigDir)
+	SystemConfigDir = filepath.Join(directory, "etc")
+
+	for name, value := range map[string]string{
+		"HOME":            filepath.Join(directory, "home"),
+		"XDG_CONFIG_HOME": filepath.Join(directory, "xdg"),
+		"TEMPDIR":         "/leaked",
+	} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+	defer func(disable bool) { homedir.DisableCache = disable }(homedir.DisableCache)
+	homedir.DisableCache = true
+
+	config := NewAppConfiguration()
+	g.Expect(config.Load(AppConfigOptions{File: explicit})).Should(gomega.Succeed())
+	g.Expect(config.ConfigFilesUsed()).Should(gomega.Equal([]string{system, user, explicit}))
+	g.Expect(config.ConfigFileUsed()).Should(gomega.Equal(explicit))
+	g.Expect(config.Executable()).Should(gomega.Equal("/usr/bin/restic"))
+	g.Expect(config.Tempdir()).Should(gomega.Equal("/home/user/tmp"))
+	g.Expect(config.StateDir()).Should(gomega.Equal("/srv/state"))
+	g.Expect(config.EmailRecipients()).Should(gomega.Equal([]string{"ops@example.com", "user@example.com"}))
+	g.Expect(config.Origin("tempdir")).Should(gomega.Equal(user))
+
+	// Environment overlays, then (prefixed) environment variables, then overrides
+	os.Setenv("RESTIC_MANAGER_EXECUTABLE", "/opt/restic")
+	defer os.Unsetenv("RESTIC_MANAGER_EXECUTABLE")
+
+	config = NewAppConfiguration()
+	g.Expect(config.Load(AppConfigOptions{
+		Environment: "staging",
+		Overrides:   []string{"state-dir=/override", "email.recipients=[a@example.com, b@example.com]"},
+	})).Should(gomega.Succeed())
+	g.Expect(config.Tempdir()).Should(gomega.Equal("/staging/tmp"))
+	g.Expect(config.Origin("tempdir")).Should(gomega.Equal("environments.staging"))
+	g.Expect(config.Executable()).Should(gomega.Equal("/opt/restic"))
+	g.Expect(config.StateDir()).Should(gomega.Equal("/override"))
+	g.Expect(config.Origin("state-dir")).Should(gomega.Equal(OriginOverride))
+	g.Expect(config.EmailRecipients()).Should(gomega.Equal([]string{"a@example.com", "b@example.com"}))
+
+	// Overrides take precedence over environment variables for the same key
+	os.Setenv("RESTIC_MANAGER_EMAIL_SMTP_PORT", "26")
+	defer os.Unsetenv("RESTIC_MANAGER_EMAIL_SMTP_PORT")
+
+	config = NewAppConfiguration()
+	g.Expect(config.Load(AppConfigOptions{})).Should(gomega.Succeed())
+	g.Expect(config.viper.GetInt("email.smtp.port")).Should(gomega.Equal(26))
+	g.Expect(config.NewMailer().SMTP.Port).Should(gomega.Equal(26))
+
+	config = NewAppConfiguration()
+	g.Expect(config.Load(AppConfigOptions{Overrides: []string{"email.smtp.port=25"}})).Should(gomega.Succeed())
+	g.Expect(config.viper.GetInt("email.smtp.port")).Should(gomega.Equal(25))
+	g.Expect(config.NewMailer().SMTP.Port).Should(gomega.Equal(25))
+	g.Expect(config.NewMailer().SMTP.Host).Should(gomega.Equal("mail.example.com"))
+
+	g.Expect(NewAppConfiguration().Load(AppConfigOptions{Environment: "production"})).ShouldNot(gomega.Succeed())
+	g.Expect(NewAppConfiguration().Load(AppConfigOptions{Overrides: []string{"tempdir"}})).ShouldNot(gomega.Succeed())
+}
@@ -0,0 +1,114 @@
+package resticmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Check modes, i.e., how much of the repository the check operation verifies.
+const (
+	// CheckMetadata verifies the repository structure only, without reading pack data.
+	CheckMetadata = "metadata"
+	// CheckSubset additionally reads one part (n/N) of the pack data, rotating through
+	// the parts across runs, so that the whole repository is read over a cycle of N runs.
+	CheckSubset = "subset"
+	// CheckBudget reads successive parts (as CheckSubset) until a time budget is exhausted.
+	CheckBudget = "budget"
+)
+
+// CheckModes lists the supported check modes.
+var CheckModes = []string{CheckMetadata, CheckSubset, CheckBudget}
+
+// CheckState encapsulates the data verification progress of a repository.
+type CheckState struct {
+	// Repository is the (redacted) repository location.
+	Repository string
+	// ID is the restic repository ID (empty if unknown).
+	ID string
+	// LastCheck is the time of the most-recent successful check (of any mode).
+	LastCheck time.Time
+	// Parts holds the time at which each part (1/N to N/N) of the pack data was last read.
+	Parts []time.Time
+	// Next is the next part (0-based) to read.
+	Next int
+}
+
+// checkStateName returns the state name of a repository: its ID, if known, so
+// that, e.g., rotating disks mounted at the same location are tracked
+// independently; otherwise, its location, qualified by a hash of the location
+// so that distinct locations cannot share a (sanitised) name.
+func checkStateName(id string, repository string) string {
+
+	if id != "" {
+		return "id-" + id
+	}
+
+	location := RedactString(repository)
+	hash := sha256.Sum256([]byte(location))
+
+	return location + "-" + hex.EncodeToString(hash[:4])
+}
+
+// reset (re-)initialises the state for a number of parts, if it differs from the current number.
+func (state *CheckState) reset(parts int) {
+
+	if len(state.Parts) == parts {
+		return
+	}
+
+	state.Parts = make([]time.Time, parts)
+	state.Next = 0
+}
+
+// NextPart returns the next part to read, as a restic --read-data-subset value (e.g., "3/10").
+func (state *CheckState) NextPart() string {
+
+	return fmt.Sprintf("%d/%d", state.Next+1, len(state.Parts))
+}
+
+// markRead records that the next part was read at the specified time, and advances to the following part.
+func (state *CheckState) markRead(when time.Time) {
+
+	state.Parts[state.Next] = when
+	state.Next = (state.Next + 1) % len(state.Parts)
+}
+
+// OldestRead returns the earliest time at which any part was last read (zero if
+// some part has never been read); the whole repository has been read since then.
+func (state *CheckState) OldestRead() time.Time {
+
+	var oldest time.Time
+	for i, when := range state.Parts {
+		if when.IsZero() {
+			return time.Time{}
+		}
+		if i == 0 || when.Before(oldest) {
+			oldest = when
+		}
+	}
+
+	return oldest
+}
+
+// SaveCheckState records the data verification progress of a repository.
+func (appConfig *AppConfiguration) SaveCheckState(state *CheckState) error {
+
+	return appConfig.saveState("checks", checkStateName(state.ID, state.Repository), state)
+}
+
+// LoadCheckState retrieves the data verification progress of the repository
+// with the specified ID (if known, otherwise at the specified location); a new,
+// empty state is returned if it has never been recorded.
+func (appConfig *AppConfiguration) LoadCheckState(id string, repository string) (*CheckState, error) {
+
+	state := &CheckState{Repository: RedactString(repository), ID: id}
+
+	if err := appConfig.loadState("checks", checkStateName(id, repository), state); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return state, nil
+}
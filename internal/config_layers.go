@@ -0,0 +1,186 @@
+package resticmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/i-am-david-fernandez/glog"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// SystemConfigDir is the directory holding the system-wide application configuration.
+var SystemConfigDir = "/etc/restic-manager"
+
+// EnvPrefix is the prefix of environment variables that override application
+// configuration values, e.g., RESTIC_MANAGER_EXECUTABLE for "executable" and
+// RESTIC_MANAGER_EMAIL_SMTP_HOST for "email.smtp.host".
+const EnvPrefix = "RESTIC_MANAGER"
+
+// appConfigName is the name (without extension) of the system and user configuration files.
+const appConfigName = "config"
+
+// legacyAppConfigName is the name (without extension) of the per-user configuration
+// file in the home directory, and of the project configuration file in the working directory.
+const legacyAppConfigName = ".restic-manager"
+
+// environmentsKey is the application configuration key holding named
+// environments, each of which may be overlaid on the base configuration.
+const environmentsKey = "environments"
+
+// OriginOverride is the recorded origin of values set on the command line (i.e., via --set).
+const OriginOverride = "--set"
+
+// AppConfigOptions specifies the sources of the application configuration.
+type AppConfigOptions struct {
+	// File is an explicitly specified configuration file, overlaid on all others.
+	File string
+	// Environment is the name of an environment (see environmentsKey) to overlay.
+	Environment string
+	// Overrides are "key=value" assignments (with dotted keys), overlaid last.
+	Overrides []string
+}
+
+// findConfigFile returns the first existing file, within a directory, with the
+// specified name and any of the (viper-)supported extensions, or "" if there is none.
+func findConfigFile(directory string, name string) string {
+
+	for _, ext := range viper.SupportedExts {
+		filename := filepath.Join(directory, name+"."+ext)
+		if stat, err := os.Stat(filename); err == nil && !stat.IsDir() {
+			return filename
+		}
+	}
+
+	return ""
+}
+
+// userConfigDir returns the directory holding the per-user application configuration,
+// i.e., $XDG_CONFIG_HOME/restic-manager (or ~/.config/restic-manager).
+func userConfigDir(home string) string {
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "restic-manager")
+	}
+
+	return filepath.Join(home, ".config", "restic-manager")
+}
+
+// appConfigLayers returns the existing application configuration files, lowest
+// precedence first: system, user (XDG, then the legacy home directory file),
+// project (the working directory) and, finally, the explicitly specified file
+// (which is included even if it does not exist).
+func appConfigLayers(file string) []string {
+
+	candidates := []string{findConfigFile(SystemConfigDir, appConfigName)}
+
+	if home, err := homedir.Dir(); err != nil {
+		glog.Warningf("Error determining home directory: %v", err)
+	} else {
+		candidates = append(candidates,
+			findConfigFile(userConfigDir(home), appConfigName),
+			findConfigFile(home, legacyAppConfigName),
+		)
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, findConfigFile(cwd, legacyAppConfigName))
+	}
+
+	candidates = append(candidates, file)
+
+	layers := make([]string, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, filename := range candidates {
+		if filename == "" {
+			continue
+		}
+		absolute, err := filepath.Abs(filename)
+		if err != nil {
+			absolute = filename
+		}
+		if seen[absolute] {
+			continue
+		}
+		seen[absolute] = true
+		layers = append(layers, filename)
+	}
+
+	return layers
+}
+
+// parseOverride parses a "key=value" assignment (e.g., "email.smtp.port=25")
+// into a settings overlay. The value is interpreted as YAML, so that, e.g.,
+// numbers, booleans and flow lists ("[a, b]") are supported.
+func parseOverride(assignment string) (map[string]interface{}, error) {
+
+	index := strings.Index(assignment, "=")
+	if index <= 0 {
+		return nil, fmt.Errorf("Invalid override %q (expected key=value)", assignment)
+	}
+
+	key := strings.ToLower(strings.TrimSpace(assignment[:index]))
+	text := assignment[index+1:]
+
+	var value interface{}
+	if err := yamlv3.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("Invalid override value for %s: %v", key, err)
+	}
+	if value == nil && strings.TrimSpace(text) != "null" && strings.TrimSpace(text) != "~" {
+		// An empty value is an empty string, rather than null
+		value = text
+	}
+
+	elements := strings.Split(key, ".")
+	for _, element := range elements {
+		if element == "" {
+			return nil, fmt.Errorf("Invalid override key %q", key)
+		}
+	}
+
+	overlay := map[string]interface{}{elements[len(elements)-1]: value}
+	for i := len(elements) - 2; i >= 0; i-- {
+		overlay = map[string]interface{}{elements[i]: overlay}
+	}
+
+	return overlay, nil
+}
+
+// environmentNames returns the (sorted) names of the environments defined in settings.
+func environmentNames(settings map[string]interface{}) []string {
+
+	environments, _ := settings[environmentsKey].(map[string]interface{})
+
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// applyEnvironment overlays a named environment onto settings.
+func applyEnvironment(settings map[string]interface{}, name string, origins map[string]string) error {
+
+	environments, _ := settings[environmentsKey].(map[string]interface{})
+
+	overlay, ok := environments[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("Unknown environment %q (defined: %s)", name, strings.Join(environmentNames(settings), ", "))
+	}
+
+	overlayMap, ok := overlay.(map[string]interface{})
+	if !ok {
+		if overlay == nil {
+			return nil
+		}
+		return fmt.Errorf("Environment %q is not a mapping", name)
+	}
+
+	return mergeSettings(settings, overlayMap, joinKey(environmentsKey, strings.ToLower(name)), origins, "")
+}
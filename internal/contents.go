@@ -0,0 +1,95 @@
+package resticmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ContentAssertion encapsulates a requirement on the content of each snapshot,
+// e.g., that it contains database files of a plausible size.
+type ContentAssertion struct {
+	// Path is a path or glob pattern (relative to the profile source, unless
+	// absolute). Files at or beneath matching paths count towards the assertion.
+	Path string `mapstructure:"path"`
+	// MinSize is the minimum total size of the matching files (e.g., "1gb" or "1.5G"; see sizeInBytes), if any.
+	MinSize string `mapstructure:"min-size"`
+	// MinFiles is the minimum number of matching files, if positive.
+	MinFiles int `mapstructure:"min-files"`
+}
+
+// Validate returns an error if the assertion has no path, or an invalid pattern or minimum size.
+func (assertion ContentAssertion) Validate() error {
+
+	if strings.TrimSpace(assertion.Path) == "" {
+		return fmt.Errorf("missing path")
+	}
+
+	if _, err := filepath.Match(assertion.Path, ""); err != nil {
+		return fmt.Errorf("invalid path pattern %q: %v", assertion.Path, err)
+	}
+
+	if assertion.MinSize != "" {
+		if _, err := sizeInBytes(assertion.MinSize); err != nil {
+			return err
+		}
+	}
+
+	if assertion.MinFiles < 0 {
+		return fmt.Errorf("invalid min-files %d", assertion.MinFiles)
+	}
+
+	return nil
+}
+
+// pathMatches returns true if a path matches, or is beneath a path matching, the pattern.
+func pathMatches(pattern string, path string) bool {
+
+	for ; path != "/" && path != "." && path != ""; path = filepath.Dir(path) {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns an error if the snapshot entries do not satisfy the assertion.
+// The assertion path must be absolute (see ProfileConfiguration.ContentAssertions).
+func (assertion ContentAssertion) Check(nodes []Node) error {
+
+	entries := 0
+	files := 0
+	var size int64
+
+	for _, node := range nodes {
+		if !pathMatches(assertion.Path, node.Path) {
+			continue
+		}
+		entries++
+		if node.Type == "file" {
+			files++
+			size += node.Size
+		}
+	}
+
+	if entries == 0 {
+		return fmt.Errorf("snapshot contains nothing matching %s", assertion.Path)
+	}
+
+	if assertion.MinFiles > 0 && files < assertion.MinFiles {
+		return fmt.Errorf("snapshot contains %d file(s) matching %s, fewer than the required %d", files, assertion.Path, assertion.MinFiles)
+	}
+
+	if assertion.MinSize != "" {
+		minSize, err := sizeInBytes(assertion.MinSize)
+		if err != nil {
+			return err
+		}
+		if size < minSize {
+			return fmt.Errorf("snapshot contains %s matching %s, less than the required %s", HumanBytes(size), assertion.Path, HumanBytes(minSize))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package resticmanager
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestContentAssertions(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	for text, size := range map[string]int64{
+		"512":   512,
+		"512b":  512,
+		"2kb":   2 * 1024,
+		"3 MB":  3 * 1024 * 1024,
+		"1g":    1024 * 1024 * 1024,
+		"1.5gb": 1536 * 1024 * 1024,
+		"50G":   50 * 1024 * 1024 * 1024,
+		"1.5T":  1536 * 1024 * 1024 * 1024,
+		"1.5":   2,
+		"kb":    -1,
+		"1.gb":  -1,
+		"lots":  -1,
+	} {
+		value, err := sizeInBytes(text)
+		if size < 0 {
+			g.Expect(err).Should(gomega.HaveOccurred(), text)
+		} else {
+			g.Expect(value).Should(gomega.Equal(size), text)
+		}
+	}
+
+	profile := newTestProfile(map[string]interface{}{
+		"source": "/srv",
+		"must-contain": []interface{}{
+			map[string]interface{}{"path": "db/*.sql", "min-size": "1kb", "min-files": 2},
+			map[string]interface{}{"path": "/etc/app"},
+		},
+	})
+
+	assertions := profile.ContentAssertions()
+	g.Expect(assertions).Should(gomega.Equal([]ContentAssertion{
+		{Path: "/srv/db/*.sql", MinSize: "1kb", MinFiles: 2},
+		{Path: "/etc/app"},
+	}))
+	for _, assertion := range assertions {
+		g.Expect(assertion.Validate()).Should(gomega.Succeed())
+	}
+	g.Expect(ContentAssertion{MinFiles: 1}.Validate()).ShouldNot(gomega.Succeed())
+	g.Expect(ContentAssertion{Path: "/srv/[", MinFiles: 1}.Validate()).ShouldNot(gomega.Succeed())
+	g.Expect(ContentAssertion{Path: "/srv", MinSize: "big"}.Validate()).ShouldNot(gomega.Succeed())
+
+	nodes := []Node{
+		{Type: "dir", Path: "/srv"},
+		{Type: "dir", Path: "/srv/db"},
+		{Type: "file", Path: "/srv/db/a.sql", Size: 800},
+		{Type: "file", Path: "/srv/db/b.sql", Size: 800},
+		{Type: "file", Path: "/srv/db/notes.txt", Size: 100000},
+		{Type: "dir", Path: "/etc/app"},
+		{Type: "file", Path: "/etc/app/conf/app.yml", Size: 10},
+	}
+
+	g.Expect(assertions[0].Check(nodes)).Should(gomega.Succeed())
+	g.Expect(assertions[1].Check(nodes)).Should(gomega.Succeed())
+
+	// Too few, too small or entirely missing
+	g.Expect(assertions[0].Check(nodes[:3])).Should(gomega.MatchError(gomega.ContainSubstring("1 file(s) matching /srv/db/*.sql, fewer than the required 2")))
+	g.Expect(ContentAssertion{Path: "/srv/db/*.sql", MinSize: "2kb"}.Check(nodes)).Should(gomega.MatchError(gomega.ContainSubstring("less than the required")))
+	g.Expect(assertions[1].Check(nodes[:5])).Should(gomega.MatchError(gomega.ContainSubstring("nothing matching /etc/app")))
+}
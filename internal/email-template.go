@@ -0,0 +1,316 @@
+package resticmanager
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	textTemplate "text/template"
+)
+
+// DefaultEmailTemplate is the built-in (html) email template.
+//
+// It is composed of named blocks ("style", "header", "profile", "operations",
+// "backup", "diff", "anomalies", "prune", "verify", "snapshots", "pins", "log-summary", "log-records" and "footer"), any
+// of which may be overridden by a subsequent template layer containing only the
+// corresponding {{define "name"}}...{{end}} definition.
+const DefaultEmailTemplate = `
+<html>
+
+<head>
+	<style>
+	{{block "style" .}}
+		.code {
+			font-family: monospace;
+			white-space: pre;
+			vertical-align: baseline;
+			text-align: left;
+		}
+
+		.debug {
+			color: darkgray;
+			display: table-row;
+		}
+
+		.info {
+			color: steelblue;
+			display: table-row;
+		}
+
+		.notice {
+			color: seagreen;
+			display: table-row;
+		}
+
+		.warning {
+			color: orange;
+			display: table-row;
+		}
+
+		.error {
+			color: darkred;
+			display: table-row;
+		}
+
+		.critical {
+			color: darkorchid;
+			display: table-row;
+		}
+	{{end}}
+	</style>
+
+</head>
+
+<body>
+
+{{block "header" .}}
+<div>{{.Preamble}}</div>
+{{end}}
+
+{{block "profile" .}}
+{{if .Profile.Name}}
+<h2>Profile {{.Profile.Name}}</h2>
+<table>
+	<tr><th>Source</th><td class="code">{{.Profile.Source}}</td></tr>
+	<tr><th>Repository</th><td class="code">{{.Profile.Repository}}</td></tr>
+	<tr><th>Tags</th><td>{{range .Profile.Tags}}{{.}} {{end}}</td></tr>
+	<tr><th>Host</th><td>{{.Hostname}}</td></tr>
+	<tr><th>Started</th><td>{{.Start.Format "2006-01-02 15:04:05"}}</td></tr>
+	<tr><th>Duration</th><td>{{humanDuration .Duration}}</td></tr>
+</table>
+{{end}}
+{{end}}
+
+{{block "operations" .}}
+{{if .Operations}}
+<h2>Operations</h2>
+<table>
+<tr>
+	<th>Operation</th>
+	<th>Status</th>
+	<th>Duration</th>
+</tr>
+{{range .Operations}}
+<tr class="code {{if eq .Status "failed"}}error{{else}}info{{end}}">
+	<td>{{.Name}}</td>
+	<td>{{.Status}}</td>
+	<td>{{humanDuration .Duration}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{block "backup" .}}
+{{with .Backup}}
+<h2>Backup</h2>
+<table>
+	<tr><th>Snapshot</th><td class="code">{{.SnapshotID}}</td></tr>
+	<tr><th>Files</th><td>{{.FilesNew}} new, {{.FilesChanged}} changed, {{.FilesUnmodified}} unmodified</td></tr>
+	<tr><th>Processed</th><td>{{.FilesProcessed}} files, {{humanBytes .BytesProcessed}}</td></tr>
+	<tr><th>Added</th><td>{{humanBytes .BytesAdded}}</td></tr>
+</table>
+{{end}}
+{{end}}
+
+{{block "diff" .}}
+{{with .Diff}}
+<h2>Changes since previous snapshot</h2>
+<table>
+	<tr><th>Files</th><td>{{.FilesNew}} new, {{.FilesRemoved}} removed, {{.FilesChanged}} changed</td></tr>
+	<tr><th>Dirs</th><td>{{.DirsNew}} new, {{.DirsRemoved}} removed</td></tr>
+	<tr><th>Data</th><td>{{humanBytes .BytesAdded}} added, {{humanBytes .BytesRemoved}} removed</td></tr>
+</table>
+{{end}}
+{{end}}
+
+{{block "anomalies" .}}
+{{if .Anomalies}}
+<h2>Anomalies</h2>
+<table>
+{{range .Anomalies}}
+<tr class="code warning"><td>{{.}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{block "prune" .}}
+{{with .Prune}}
+<h2>Prune{{if .DryRun}} (not needed){{end}}</h2>
+<table>
+	<tr><th>Unused</th><td>{{humanBytes .UnusedBytes}} of {{humanBytes .TotalBytes}}</td></tr>
+	{{if not .DryRun}}
+	<tr><th>Repacked</th><td>{{humanBytes .RepackedBytes}}</td></tr>
+	<tr><th>Reclaimed</th><td>{{humanBytes .ReclaimedBytes}}</td></tr>
+	<tr><th>Remaining</th><td>{{humanBytes .RemainingBytes}} ({{humanBytes .RemainingUnusedBytes}} unused)</td></tr>
+	{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{block "verify" .}}
+{{with .Verify}}
+<h2>Restore verification</h2>
+<table>
+	<tr><th>Snapshot</th><td class="code">{{.Snapshot}}</td></tr>
+	<tr><th>Files</th><td>{{.Count "matched"}} matched, {{.Count "drifted"}} drifted (changed since the snapshot), {{.Count "mismatched"}} mismatched</td></tr>
+</table>
+{{with .Mismatches}}
+<table>
+<tr>
+	<th>Path</th>
+	<th>Mismatch</th>
+</tr>
+{{range .}}
+<tr class="code error">
+	<td>{{.Path}}</td>
+	<td>{{.Detail}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+{{end}}
+
+{{block "snapshots" .}}
+{{if .Snapshots}}
+<h2>Snapshots</h2>
+<table>
+<tr>
+	<th>ID</th>
+	<th>Time</th>
+	<th>Host</th>
+	<th>Tags</th>
+</tr>
+{{range .Snapshots}}
+<tr class="code">
+	<td>{{.ShortID}}</td>
+	<td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+	<td>{{.Hostname}}</td>
+	<td>{{range .Tags}}{{.}} {{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{block "pins" .}}
+{{if .Pins}}
+<h2>Pinned snapshots</h2>
+<table>
+<tr>
+	<th>ID</th>
+	<th>Time</th>
+	<th>Pinned until</th>
+	<th>Note</th>
+</tr>
+{{range .Pins}}
+<tr class="code">
+	<td>{{.Snapshot.ShortID}}</td>
+	<td>{{.Snapshot.Time.Format "2006-01-02 15:04:05"}}</td>
+	<td>{{.Expiry}}</td>
+	<td>{{.Note}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{block "log-summary" .}}
+<h2>Log Summary</h2>
+<table>
+	{{range .LogSummary}}
+	<tr class="code {{.Level}}">
+		<th>Messages at level {{.Level}}</th>
+		<td>{{.Count}}</td>
+	</tr>
+	{{end}}
+</table>
+{{end}}
+
+{{block "log-records" .}}
+<h2>Log Records</h2>
+<table>
+<tr>
+	<th>Time</th>
+	<th>Level</th>
+	<th>Message</th>
+</tr>
+{{range .LogRecords}}
+<tr class="code {{.Level}}">
+	<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
+	<td>{{.Level}}</td>
+	<td>{{.Message}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+
+{{block "footer" .}}
+<p>restic-manager {{.Version}}</p>
+{{end}}
+
+</body>
+
+</html>
+`
+
+// readTemplateFile reads a template file, resolving a relative filename against the specified base file.
+func readTemplateFile(filename string, relativeTo string) (string, error) {
+
+	if !filepath.IsAbs(filename) && relativeTo != "" {
+		filename = filepath.Join(filepath.Dir(relativeTo), filename)
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("Could not read template file: %v", err)
+	}
+
+	return string(content), nil
+}
+
+// ExecuteTemplateLayers parses a sequence of (html) template layers and executes the result.
+// Each layer is parsed on top of the preceding layers, such that a layer may either
+// replace the template entirely or (re-)define only some of its named blocks.
+func ExecuteTemplateLayers(layers []string, data interface{}) (string, error) {
+
+	engine := template.New("message").Funcs(template.FuncMap(TemplateFunctions()))
+
+	for i, layer := range layers {
+		if _, err := engine.Parse(layer); err != nil {
+			return "", fmt.Errorf("Could not parse template layer %d: %v", i, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+
+	if err := engine.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("Could not execute template: %v", err)
+	}
+
+	return buffer.String(), nil
+}
+
+// ExecuteTextTemplateLayers parses a sequence of (plain-text) template layers and executes the result.
+// Layers are combined as per ExecuteTemplateLayers.
+func ExecuteTextTemplateLayers(layers []string, data interface{}) (string, error) {
+
+	engine := textTemplate.New("message").Funcs(textTemplate.FuncMap(TemplateFunctions()))
+
+	for i, layer := range layers {
+		if _, err := engine.Parse(layer); err != nil {
+			return "", fmt.Errorf("Could not parse text template layer %d: %v", i, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+
+	if err := engine.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("Could not execute text template: %v", err)
+	}
+
+	return buffer.String(), nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"testing"
+	"time"
 
 	"github.com/i-am-david-fernandez/glog"
 	"github.com/onsi/gomega"
@@ -25,21 +26,26 @@ func TestEmailTemplate(t *testing.T) {
 	glog.Errorf("Error message")
 	glog.Criticalf("Critical message")
 
-	data := struct {
-		Preamble   string
-		LogSummary []*glog.RecordSummary
-		LogRecords []glog.Record
-	}{
-		"Preamble",
-		sessionBackend.Summary(),
-		sessionBackend.Get(glog.Debug),
-	}
+	data := NewReport(nil)
+	data.Preamble = "Preamble"
+	data.LogSummary = sessionBackend.Summary()
+	data.LogRecords = sessionBackend.Get(glog.Debug)
+	data.Profile.Name = "profile"
+	data.StartOperation("backup").Finish(nil)
+	data.Backup = NewBackupSummary("Files: 1 new, 0 changed, 0 unmodified")
+	data.Diff = NewSnapshotDiff("")
+	data.Prune = NewPruneSummary("")
+	data.Anomalies = []string{"anomaly"}
+	data.Verify = &RestoreVerification{Files: []FileVerification{{Path: "/a", Outcome: VerifyMismatched, Detail: "differs"}}}
+	data.Snapshots = []Snapshot{{ShortID: "1a2b3c4d", Tags: []string{"tag"}}}
+	data.Pins = PinsOf([]Snapshot{{ShortID: "1a2b3c4d", Tags: PinTags(time.Now(), "note")}})
+	data.Finish()
 
 	appConfig := NewAppConfiguration()
 
 	tpl := appConfig.EmailTemplate()
 
-	tplEngine, err := template.New("test").Parse(tpl)
+	tplEngine, err := template.New("test").Funcs(template.FuncMap(TemplateFunctions())).Parse(tpl)
 	if err != nil {
 		fmt.Printf("%v", err)
 	}
@@ -55,3 +61,58 @@ func TestEmailTemplate(t *testing.T) {
 
 	fmt.Println(buffer.String())
 }
+
+func TestMailMessageCompose(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	message := NewMailMessage()
+	message.Sender = "sender@example.com"
+	message.AddRecipients("recipient@example.com")
+	message.Subject = "Subject"
+	message.AddContent("<p>Html content</p>")
+	message.AddTextContent("Text content")
+	message.Attach("small.txt", []byte("small"), 1024)
+	message.Attach("large.txt", bytes.Repeat([]byte("large"), 1024), 1024)
+
+	g.Expect(message.Attachments).Should(gomega.HaveLen(2))
+	g.Expect(message.Attachments[0].Name).Should(gomega.Equal("small.txt"))
+	g.Expect(message.Attachments[1].Name).Should(gomega.Equal("large.txt.gz"))
+
+	var buffer bytes.Buffer
+	_, err := message.compose().WriteTo(&buffer)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	content := buffer.String()
+	g.Expect(content).Should(gomega.ContainSubstring("multipart/mixed"))
+	g.Expect(content).Should(gomega.ContainSubstring("multipart/alternative"))
+	g.Expect(content).Should(gomega.ContainSubstring("text/plain"))
+	g.Expect(content).Should(gomega.ContainSubstring("text/html"))
+	g.Expect(content).Should(gomega.ContainSubstring(`filename="large.txt.gz"`))
+}
+
+func TestEmailTemplateLayers(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	data := NewSyntheticReport(nil)
+	data.Preamble = "Preamble"
+
+	// A layer that only (re-)defines a block overrides just that block
+	content, err := ExecuteTemplateLayers([]string{
+		DefaultEmailTemplate,
+		`{{define "header"}}<h1>Custom header: {{.Preamble}}</h1>{{end}}`,
+	}, data)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(content).Should(gomega.ContainSubstring("<h1>Custom header: Preamble</h1>"))
+	g.Expect(content).ShouldNot(gomega.ContainSubstring("<div>Preamble</div>"))
+	g.Expect(content).Should(gomega.ContainSubstring("<h2>Log Summary</h2>"))
+
+	// A layer with a body replaces the template entirely
+	content, err = ExecuteTemplateLayers([]string{
+		DefaultEmailTemplate,
+		`Replaced {{humanBytes .Backup.BytesAdded}}`,
+	}, data)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(content).Should(gomega.Equal("Replaced 42.000 MiB"))
+}
@@ -0,0 +1,246 @@
+package resticmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// legacySubstitutions maps the deprecated angle-bracket substitutions to their template equivalents.
+var legacySubstitutions = map[string]string{
+	"<source>": "{{.source}}",
+	"<repo>":   "{{.repo}}",
+}
+
+// unexpandedKeys lists the profile keys whose values are never template-expanded
+// (e.g., email templates, which are rendered later against a report).
+var unexpandedKeys = []string{
+	"include",
+	"matrix",
+	"email.template",
+	"email.text-template",
+}
+
+// expansionOrder lists keys that are expanded first (in order), so that other
+// values may refer to their expanded values, e.g., "{{.repo}}/restic-manager.log".
+var expansionOrder = []string{
+	"name",
+	"source",
+	"repo",
+}
+
+// MigrateLegacySubstitutions replaces deprecated angle-bracket substitutions
+// (e.g., "<source>") with their template equivalents (e.g., "{{.source}}").
+func MigrateLegacySubstitutions(value string) string {
+
+	for legacy, replacement := range legacySubstitutions {
+		value = strings.Replace(value, legacy, replacement, -1)
+	}
+
+	return value
+}
+
+// hasLegacySubstitution returns true if a value contains a deprecated angle-bracket substitution.
+func hasLegacySubstitution(value string) bool {
+
+	for legacy := range legacySubstitutions {
+		if strings.Contains(value, legacy) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpansionFunctions returns the set of helper functions available to configuration value templates.
+// now is the (fixed) time used by the date and now functions.
+func ExpansionFunctions(now time.Time) template.FuncMap {
+
+	return template.FuncMap{
+		// env returns the value of an environment variable, or the (optional) fallback if it is unset or empty.
+		"env": func(name string, fallback ...string) string {
+			if value := os.Getenv(name); value != "" {
+				return value
+			}
+			return strings.Join(fallback, "")
+		},
+		"hostname": func() string {
+			hostname, err := os.Hostname()
+			if err != nil {
+				glog.Warningf("Could not determine hostname: %v", err)
+			}
+			return hostname
+		},
+		"username": func() string {
+			if u, err := user.Current(); err == nil {
+				return u.Username
+			}
+			return os.Getenv("USER")
+		},
+		// date formats the current time using a Go time layout, e.g., {{date "2006-01-02"}}.
+		"date": func(layout string) string {
+			return now.Format(layout)
+		},
+		"now": func() time.Time {
+			return now
+		},
+		"joinPath": func(elements ...string) string {
+			return filepath.Join(elements...)
+		},
+	}
+}
+
+// missingKeyError is returned by expandString if a template refers to a key absent from the context.
+type missingKeyError struct {
+	err error
+}
+
+func (e missingKeyError) Error() string {
+	return e.err.Error()
+}
+
+// expandString expands a single configuration value template against a context.
+func expandString(value string, context map[string]interface{}, functions template.FuncMap) (string, error) {
+
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tmpl, err := template.New("value").Funcs(functions).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return value, err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, context); err != nil {
+		if strings.Contains(err.Error(), "map has no entry for key") {
+			return value, missingKeyError{err}
+		}
+		return value, err
+	}
+
+	return buffer.String(), nil
+}
+
+// expansionContext returns the template context for expanding settings,
+// i.e., the settings themselves along with any extra (e.g., matrix) values.
+func expansionContext(settings map[string]interface{}, extra map[string]interface{}) map[string]interface{} {
+
+	context := make(map[string]interface{}, len(settings)+len(extra))
+	for k, v := range settings {
+		context[k] = v
+	}
+	for k, v := range extra {
+		context[k] = v
+	}
+
+	return context
+}
+
+// expandSettings template-expands every string value within settings (in
+// place), with the settings themselves (and extra) as the template context.
+// Deprecated angle-bracket substitutions are converted (with a warning), and
+// values referring to unknown keys are left unexpanded (with a warning). Secret
+// values (e.g., the password) are exempt from both: they are used verbatim, or
+// must expand fully, as a wrong secret would otherwise go unnoticed.
+// origins (if not nil) is used to identify the file of a problematic value.
+func expandSettings(settings map[string]interface{}, extra map[string]interface{}, origins map[string]string) []error {
+
+	functions := ExpansionFunctions(time.Now())
+	errs := make([]error, 0)
+
+	expand := func(key string, value string, context map[string]interface{}) string {
+
+		secret := isSecretKey(key)
+
+		if hasLegacySubstitution(value) && !secret {
+			glog.Warningf("%s (%s): the <source>/<repo> substitution syntax is deprecated; use {{.source}}/{{.repo}} (see \"config migrate\")", key, originOf(origins, key))
+			value = MigrateLegacySubstitutions(value)
+		}
+
+		expanded, err := expandString(value, context, functions)
+		if _, ok := err.(missingKeyError); ok && !secret {
+			glog.Warningf("%s (%s): left unexpanded, as it refers to an unknown key: %v", key, originOf(origins, key), err)
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("Could not expand %s (%s): %v", key, originOf(origins, key), err))
+		}
+
+		return expanded
+	}
+
+	// Expand the primary values first, each with the context updated by its predecessors
+	for _, key := range expansionOrder {
+		if value, ok := settings[key].(string); ok {
+			settings[key] = expand(key, value, expansionContext(settings, extra))
+		}
+	}
+
+	context := expansionContext(settings, extra)
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !containsString(expansionOrder, key) {
+			settings[key] = expandValue(key, settings[key], context, expand)
+		}
+	}
+
+	return errs
+}
+
+// expandValue recursively expands the strings within a settings value.
+func expandValue(key string, value interface{}, context map[string]interface{}, expand func(string, string, map[string]interface{}) string) interface{} {
+
+	if containsString(unexpandedKeys, key) {
+		return value
+	}
+
+	switch v := value.(type) {
+
+	case string:
+		return expand(key, v, context)
+
+	case []string:
+		for i, item := range v {
+			v[i] = expand(fmt.Sprintf("%s[%d]", key, i), item, context)
+		}
+
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandValue(fmt.Sprintf("%s[%d]", key, i), item, context, expand)
+		}
+
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = expandValue(joinKey(key, k), item, context, expand)
+		}
+	}
+
+	return value
+}
+
+// originOf returns the recorded origin of a (possibly indexed) key.
+func originOf(origins map[string]string, key string) string {
+
+	if index := strings.Index(key, "["); index >= 0 {
+		key = key[:index]
+	}
+
+	if origin, ok := origins[key]; ok {
+		return origin
+	}
+
+	return "unknown origin"
+}
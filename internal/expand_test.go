@@ -0,0 +1,115 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestExpandSettings(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	os.Setenv("RESTIC_MANAGER_TEST_ROOT", "/backups")
+	defer os.Unsetenv("RESTIC_MANAGER_TEST_ROOT")
+	os.Setenv("RESTIC_MANAGER_TEST_PASSWORD", "hunter2")
+	defer os.Unsetenv("RESTIC_MANAGER_TEST_PASSWORD")
+
+	settings := map[string]interface{}{
+		"name":     "home",
+		"source":   "/home/{{.name}}",
+		"repo":     `{{joinPath (env "RESTIC_MANAGER_TEST_ROOT") .name}}`,
+		"password": `{{env "RESTIC_MANAGER_TEST_PASSWORD"}}`,
+		"exclusions": []interface{}{
+			"{{.source}}/cache",
+			"<source>/tmp",
+		},
+		"arguments": map[string]interface{}{
+			"backup": []interface{}{`--tag={{env "RESTIC_MANAGER_TEST_UNSET" "none"}}`},
+		},
+		"logging": map[string]interface{}{
+			"file": "<repo>.log",
+		},
+		"email": map[string]interface{}{
+			"template": "{{.Profile.Name}}",
+		},
+	}
+
+	errs := expandSettings(settings, nil, nil)
+	g.Expect(errs).Should(gomega.BeEmpty())
+
+	g.Expect(settings["source"]).Should(gomega.Equal("/home/home"))
+	g.Expect(settings["repo"]).Should(gomega.Equal("/backups/home"))
+	g.Expect(settings["password"]).Should(gomega.Equal("hunter2"))
+	g.Expect(settings["exclusions"]).Should(gomega.Equal([]interface{}{"/home/home/cache", "/home/home/tmp"}))
+	g.Expect(settings["arguments"].(map[string]interface{})["backup"]).Should(gomega.Equal([]interface{}{"--tag=none"}))
+	g.Expect(settings["logging"].(map[string]interface{})["file"]).Should(gomega.Equal("/backups/home.log"))
+	g.Expect(settings["email"].(map[string]interface{})["template"]).Should(gomega.Equal("{{.Profile.Name}}"))
+
+	// Unknown keys are left unexpanded
+	settings = map[string]interface{}{"source": "{{.sauce}}"}
+	errs = expandSettings(settings, nil, nil)
+	g.Expect(errs).Should(gomega.BeEmpty())
+	g.Expect(settings["source"]).Should(gomega.Equal("{{.sauce}}"))
+
+	// ... except within secrets, which are used verbatim (e.g., "<repo>") or must expand fully
+	settings = map[string]interface{}{"password": "p<repo>"}
+	g.Expect(expandSettings(settings, nil, nil)).Should(gomega.BeEmpty())
+	g.Expect(settings["password"]).Should(gomega.Equal("p<repo>"))
+	g.Expect(expandSettings(map[string]interface{}{"password": "{{.secret}}"}, nil, nil)).Should(gomega.HaveLen(1))
+
+	// Invalid templates are an error
+	errs = expandSettings(map[string]interface{}{"source": "{{.source"}, nil, nil)
+	g.Expect(errs).Should(gomega.HaveLen(1))
+
+	// Date formatting
+	now := time.Date(2019, 8, 21, 3, 52, 0, 0, time.UTC)
+	value, err := expandString(`{{date "2006-01-02"}}`, nil, ExpansionFunctions(now))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(value).Should(gomega.Equal("2019-08-21"))
+}
+
+func TestMigrateFile(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "migrate")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	content := `# A comment
+repo: /backup   # trailing comment
+logging:
+  file: <repo>.log
+exclusions: [<source>/cache, 'it''s <source>']
+arguments:
+  backup:
+    - "--exclude-file=<source>/.excludes"
+`
+	filename := filepath.Join(directory, "profile.yml")
+	g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+
+	migrated, changes, err := MigrateFile(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(changes).Should(gomega.HaveLen(4))
+	g.Expect(changes[0].Line).Should(gomega.Equal(4))
+
+	g.Expect(string(migrated)).Should(gomega.Equal(`# A comment
+repo: /backup   # trailing comment
+logging:
+  file: "{{.repo}}.log"
+exclusions: ["{{.source}}/cache", 'it''s {{.source}}']
+arguments:
+  backup:
+    - "--exclude-file={{.source}}/.excludes"
+`))
+
+	// The file itself is not modified
+	original, err := ioutil.ReadFile(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(original)).Should(gomega.Equal(content))
+}
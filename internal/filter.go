@@ -0,0 +1,567 @@
+package resticmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// ProfileFilter encapsulates profile selection criteria.
+//
+// Names are glob patterns, of which a profile name must match at least one.
+// Each entry of Tags is a tag that must be present, a tag prefixed with "!"
+// that must be absent, or a "|"-separated group of which at least one must be
+// present; all entries must be satisfied. Sources and Repositories are path
+// prefixes (or glob patterns), of which the profile source or repository
+// (respectively) must match at least one. Select is an optional selection
+// expression (see ParseSelectExpression) that must also be satisfied.
+//
+// Regardless of the other criteria, a profile must also be intended for Host
+// (see MatchesHost), which defaults to the local hostname if empty.
+type ProfileFilter struct {
+	Host         string
+	OnlyActive   bool
+	Names        []string
+	Tags         []string
+	Sources      []string
+	Repositories []string
+	Select       SelectExpression
+}
+
+// NewProfileFilter creates and returns a new, empty ProfileFilter.
+func NewProfileFilter() *ProfileFilter {
+
+	return &ProfileFilter{
+		OnlyActive: true,
+	}
+}
+
+// SkippedProfile encapsulates a profile excluded by a filter, along with the reason.
+// OtherHost is set if the profile was excluded because it is not intended for the host.
+type SkippedProfile struct {
+	Profile   *ProfileConfiguration
+	Reason    string
+	OtherHost bool
+}
+
+// filterHost returns the host against which profiles are filtered.
+func (filter ProfileFilter) filterHost() string {
+
+	if filter.Host != "" {
+		return filter.Host
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		glog.Warningf("Could not determine hostname: %v", err)
+	}
+
+	return hostname
+}
+
+// matchGlob returns true if a value matches a glob pattern (or equals it, if the pattern is invalid).
+func matchGlob(pattern string, value string) bool {
+
+	if matched, err := path.Match(pattern, value); err == nil {
+		return matched
+	}
+
+	return pattern == value
+}
+
+// matchPath returns true if a path is at or beneath a prefix, or matches it as a glob pattern.
+func matchPath(pattern string, value string) bool {
+
+	if value == "" {
+		return false
+	}
+
+	cleanPattern := filepath.Clean(pattern)
+	cleanValue := filepath.Clean(value)
+
+	if cleanValue == cleanPattern || strings.HasPrefix(cleanValue, strings.TrimSuffix(cleanPattern, string(filepath.Separator))+string(filepath.Separator)) {
+		return true
+	}
+
+	if matched, err := filepath.Match(pattern, value); err == nil && matched {
+		return true
+	}
+
+	return false
+}
+
+// hasTag returns true if the profile has a tag matching a (glob) pattern.
+func (profile *ProfileConfiguration) hasTag(pattern string) bool {
+
+	for _, tag := range profile.Tags() {
+		if matchGlob(pattern, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchesHost returns true if the profile is intended for a host, i.e., the
+// hostname matches none of the exclude-hosts patterns and, if any hosts
+// patterns are specified, at least one of them; if not, the reason is also
+// returned. Hostnames are matched case-insensitively.
+func (profile *ProfileConfiguration) MatchesHost(host string) (bool, string) {
+
+	host = strings.ToLower(host)
+
+	for _, pattern := range profile.ExcludeHosts() {
+		if matchGlob(strings.ToLower(pattern), host) {
+			return false, fmt.Sprintf("host %q is excluded (%s)", host, pattern)
+		}
+	}
+
+	hosts := profile.Hosts()
+	if len(hosts) == 0 {
+		return true, ""
+	}
+
+	for _, pattern := range hosts {
+		if matchGlob(strings.ToLower(pattern), host) {
+			return true, ""
+		}
+	}
+
+	return false, fmt.Sprintf("not intended for host %q (only %s)", host, strings.Join(hosts, ", "))
+}
+
+// matchTagCriterion checks a single tag criterion (see ProfileFilter), returning a reason if not satisfied.
+func (profile *ProfileConfiguration) matchTagCriterion(criterion string) (bool, string) {
+
+	if strings.HasPrefix(criterion, "!") {
+		tag := strings.TrimPrefix(criterion, "!")
+		if profile.hasTag(tag) {
+			return false, fmt.Sprintf("has excluded tag %q", tag)
+		}
+		return true, ""
+	}
+
+	group := strings.Split(criterion, "|")
+	for _, tag := range group {
+		if profile.hasTag(tag) {
+			return true, ""
+		}
+	}
+
+	if len(group) > 1 {
+		return false, fmt.Sprintf("has none of the tags %s", strings.Join(group, ", "))
+	}
+
+	return false, fmt.Sprintf("does not have tag %q", criterion)
+}
+
+// MatchesFilter returns true if the ProfileConfiguration matches the specified filter
+// criteria; if not, the reason is also returned.
+func (profile *ProfileConfiguration) MatchesFilter(filter ProfileFilter) (bool, string) {
+
+	if match, reason := profile.MatchesHost(filter.filterHost()); !match {
+		return false, reason
+	}
+
+	if filter.OnlyActive && (!profile.IsActive()) {
+		return false, "not active"
+	}
+
+	if len(filter.Names) > 0 {
+		matched := false
+		for _, name := range filter.Names {
+			if matchGlob(name, profile.Name()) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false, fmt.Sprintf("name %q matches none of %s", profile.Name(), strings.Join(filter.Names, ", "))
+		}
+	}
+
+	for _, criterion := range filter.Tags {
+		if match, reason := profile.matchTagCriterion(criterion); !match {
+			return false, reason
+		}
+	}
+
+	if len(filter.Sources) > 0 {
+		matched := false
+		for _, source := range filter.Sources {
+			if matchPath(source, profile.Source()) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false, fmt.Sprintf("source %q is not within any of %s", profile.Source(), strings.Join(filter.Sources, ", "))
+		}
+	}
+
+	if len(filter.Repositories) > 0 {
+		matched := false
+		for _, repo := range filter.Repositories {
+			if matchPath(repo, profile.Repository()) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false, fmt.Sprintf("repository %q is not within any of %s", profile.Repository(), strings.Join(filter.Repositories, ", "))
+		}
+	}
+
+	if filter.Select != nil {
+		if match, reason := filter.Select.Evaluate(profile); !match {
+			return false, fmt.Sprintf("does not satisfy %q: %s", filter.Select.String(), reason)
+		}
+	}
+
+	return true, ""
+}
+
+// FilterProfiles splits a set of profiles into those that match the filter criteria
+// and those that do not (along with the reason).
+func FilterProfiles(profiles []*ProfileConfiguration, filter ProfileFilter) ([]*ProfileConfiguration, []SkippedProfile) {
+
+	selected := make([]*ProfileConfiguration, 0)
+	skipped := make([]SkippedProfile, 0)
+
+	// Resolve the host once, rather than per profile
+	filter.Host = filter.filterHost()
+
+	for _, profile := range profiles {
+
+		if match, reason := profile.MatchesFilter(filter); !match {
+			glog.Debugf("Skipping profile %s (filter criteria not matched: %s).", profile.Name(), reason)
+			forHost, _ := profile.MatchesHost(filter.Host)
+			skipped = append(skipped, SkippedProfile{Profile: profile, Reason: reason, OtherHost: !forHost})
+			continue
+		}
+
+		selected = append(selected, profile)
+	}
+
+	return selected, skipped
+}
+
+// SelectExpression is a parsed profile selection expression.
+type SelectExpression interface {
+	// Evaluate returns true if the profile satisfies the expression; if not,
+	// the reason is also returned.
+	Evaluate(profile *ProfileConfiguration) (bool, string)
+	String() string
+}
+
+type selectAnd struct{ operands []SelectExpression }
+type selectOr struct{ operands []SelectExpression }
+type selectNot struct{ operand SelectExpression }
+type selectTerm struct {
+	field   string
+	pattern string
+}
+
+func (e *selectAnd) Evaluate(profile *ProfileConfiguration) (bool, string) {
+
+	for _, operand := range e.operands {
+		if match, reason := operand.Evaluate(profile); !match {
+			return false, reason
+		}
+	}
+
+	return true, ""
+}
+
+func (e *selectAnd) String() string {
+	return joinExpressions(e.operands, " and ")
+}
+
+func (e *selectOr) Evaluate(profile *ProfileConfiguration) (bool, string) {
+
+	reasons := make([]string, 0, len(e.operands))
+	for _, operand := range e.operands {
+		match, reason := operand.Evaluate(profile)
+		if match {
+			return true, ""
+		}
+		reasons = append(reasons, reason)
+	}
+
+	return false, strings.Join(reasons, " and ")
+}
+
+func (e *selectOr) String() string {
+	return joinExpressions(e.operands, " or ")
+}
+
+func (e *selectNot) Evaluate(profile *ProfileConfiguration) (bool, string) {
+
+	if match, _ := e.operand.Evaluate(profile); match {
+		return false, fmt.Sprintf("excluded by %q", e.String())
+	}
+
+	return true, ""
+}
+
+func (e *selectNot) String() string {
+	return "not " + joinExpressions([]SelectExpression{e.operand}, "")
+}
+
+func (e *selectTerm) Evaluate(profile *ProfileConfiguration) (bool, string) {
+
+	switch e.field {
+
+	case "name":
+		if matchGlob(e.pattern, profile.Name()) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("name %q does not match %q", profile.Name(), e.pattern)
+
+	case "tag":
+		if profile.hasTag(e.pattern) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("no tag matches %q", e.pattern)
+
+	case "source":
+		if matchPath(e.pattern, profile.Source()) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("source %q is not within %q", profile.Source(), e.pattern)
+
+	case "repo":
+		if matchPath(e.pattern, profile.Repository()) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("repository %q is not within %q", profile.Repository(), e.pattern)
+
+	case "active":
+		active := fmt.Sprintf("%v", profile.IsActive())
+		if active == e.pattern {
+			return true, ""
+		}
+		return false, fmt.Sprintf("active is %s", active)
+	}
+
+	return false, fmt.Sprintf("unknown field %q", e.field)
+}
+
+func (e *selectTerm) String() string {
+
+	if strings.ContainsAny(e.pattern, " ()\"") {
+		return fmt.Sprintf("%s:%q", e.field, e.pattern)
+	}
+
+	return e.field + ":" + e.pattern
+}
+
+func joinExpressions(expressions []SelectExpression, separator string) string {
+
+	parts := make([]string, len(expressions))
+	for i, expression := range expressions {
+		parts[i] = expression.String()
+		if _, isTerm := expression.(*selectTerm); !isTerm {
+			if _, isNot := expression.(*selectNot); !isNot {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+	}
+
+	return strings.Join(parts, separator)
+}
+
+// selectFields lists the fields available to selection expression terms.
+var selectFields = []string{"name", "tag", "source", "repo", "active"}
+
+// tokenizeSelectExpression splits an expression into tokens: parentheses,
+// "!" and words (which may include double-quoted sections).
+func tokenizeSelectExpression(expression string) ([]string, error) {
+
+	tokens := make([]string, 0)
+	var current strings.Builder
+	quoted := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range expression {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case (r == '(' || r == ')' || r == '!') && current.Len() == 0, r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// selectParser is a recursive-descent parser of selection expressions.
+type selectParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *selectParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *selectParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+func (p *selectParser) parseOr() (SelectExpression, error) {
+
+	operand, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []SelectExpression{operand}
+	for strings.ToLower(p.peek()) == "or" {
+		p.next()
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+
+	return &selectOr{operands: operands}, nil
+}
+
+func (p *selectParser) parseAnd() (SelectExpression, error) {
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []SelectExpression{operand}
+	for strings.ToLower(p.peek()) == "and" {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+
+	return &selectAnd{operands: operands}, nil
+}
+
+func (p *selectParser) parseNot() (SelectExpression, error) {
+
+	if token := strings.ToLower(p.peek()); token == "not" || token == "!" {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &selectNot{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *selectParser) parsePrimary() (SelectExpression, error) {
+
+	token := p.next()
+
+	switch strings.ToLower(token) {
+
+	case "":
+		return nil, errors.New("unexpected end of expression")
+
+	case "(":
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		return expression, nil
+
+	case ")", "and", "or":
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid term %q (expected FIELD:PATTERN, e.g., tag:nightly)", token)
+	}
+
+	field := strings.ToLower(parts[0])
+	if !containsString(selectFields, field) {
+		return nil, fmt.Errorf("unknown field %q in %q (expected one of %s)", parts[0], token, strings.Join(selectFields, ", "))
+	}
+
+	return &selectTerm{field: field, pattern: parts[1]}, nil
+}
+
+// ParseSelectExpression parses a profile selection expression, e.g.,
+// "tag:nightly and not name:scratch-*". Terms take the form FIELD:PATTERN,
+// where FIELD is one of name, tag, source, repo or active. Name and tag
+// patterns are globs, source and repo patterns are path prefixes (or globs)
+// and active is true or false. Terms may be negated with "not" (or "!") and
+// combined with "and" and "or" (which binds least tightly), and grouped with
+// parentheses. Patterns containing spaces may be double-quoted.
+func ParseSelectExpression(expression string) (SelectExpression, error) {
+
+	tokens, err := tokenizeSelectExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid selection expression %q: %v", expression, err)
+	}
+
+	parser := &selectParser{tokens: tokens}
+
+	result, err := parser.parseOr()
+	if err == nil && parser.position < len(tokens) {
+		err = fmt.Errorf("unexpected %q", parser.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid selection expression %q: %v", expression, err)
+	}
+
+	return result, nil
+}
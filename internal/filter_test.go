@@ -0,0 +1,163 @@
+package resticmanager
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// newTestProfile creates a profile directly from settings.
+func newTestProfile(settings map[string]interface{}) *ProfileConfiguration {
+
+	profile := NewProfileConfiguration()
+	profile.viper.MergeConfigMap(settings)
+
+	return profile
+}
+
+func TestProfileFilter(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	db := newTestProfile(map[string]interface{}{
+		"name":   "db-main",
+		"active": true,
+		"tags":   []interface{}{"nightly", "server"},
+		"source": "/srv/db",
+		"repo":   "/backup/db",
+	})
+	laptop := newTestProfile(map[string]interface{}{
+		"name":   "laptop-home",
+		"active": true,
+		"tags":   []interface{}{"laptop", "weekly"},
+		"source": "/home/user",
+		"repo":   "/backup/laptop",
+	})
+	scratch := newTestProfile(map[string]interface{}{
+		"name":   "scratch-1",
+		"active": false,
+		"tags":   []interface{}{"nightly"},
+		"source": "/srv/scratch",
+		"repo":   "/mnt/usb/scratch",
+	})
+	all := []*ProfileConfiguration{db, laptop, scratch}
+
+	names := func(profiles []*ProfileConfiguration) []string {
+		result := make([]string, len(profiles))
+		for i, profile := range profiles {
+			result[i] = profile.Name()
+		}
+		return result
+	}
+
+	selected, skipped := FilterProfiles(all, ProfileFilter{OnlyActive: true})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "laptop-home"}))
+	g.Expect(skipped).Should(gomega.HaveLen(1))
+	g.Expect(skipped[0].Reason).Should(gomega.Equal("not active"))
+
+	selected, _ = FilterProfiles(all, ProfileFilter{Names: []string{"db-*", "scratch-?"}})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "scratch-1"}))
+
+	selected, skipped = FilterProfiles(all, ProfileFilter{Tags: []string{"!laptop"}})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "scratch-1"}))
+	g.Expect(skipped[0].Reason).Should(gomega.Equal(`has excluded tag "laptop"`))
+
+	selected, skipped = FilterProfiles(all, ProfileFilter{Tags: []string{"weekly|server"}})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "laptop-home"}))
+	g.Expect(skipped[0].Reason).Should(gomega.Equal("has none of the tags weekly, server"))
+
+	selected, _ = FilterProfiles(all, ProfileFilter{Sources: []string{"/srv"}})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "scratch-1"}))
+
+	selected, _ = FilterProfiles(all, ProfileFilter{Repositories: []string{"/backup/"}})
+	g.Expect(names(selected)).Should(gomega.Equal([]string{"db-main", "laptop-home"}))
+
+	// A prefix must match whole path elements
+	selected, _ = FilterProfiles(all, ProfileFilter{Sources: []string{"/srv/d"}})
+	g.Expect(selected).Should(gomega.BeEmpty())
+}
+
+func TestProfileHosts(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	nas := newTestProfile(map[string]interface{}{
+		"name":          "nas",
+		"active":        true,
+		"hosts":         []interface{}{"NAS-*"},
+		"exclude-hosts": []interface{}{"nas-backup"},
+	})
+	anywhere := newTestProfile(map[string]interface{}{
+		"name":          "anywhere",
+		"active":        true,
+		"exclude-hosts": []interface{}{"laptop"},
+	})
+	inactive := newTestProfile(map[string]interface{}{
+		"name":   "inactive",
+		"active": false,
+		"hosts":  []interface{}{"laptop"},
+	})
+	all := []*ProfileConfiguration{nas, anywhere, inactive}
+
+	selected, skipped := FilterProfiles(all, ProfileFilter{Host: "nas-main", OnlyActive: true})
+	g.Expect(selected).Should(gomega.Equal([]*ProfileConfiguration{nas, anywhere}))
+	g.Expect(skipped).Should(gomega.HaveLen(1))
+	g.Expect(skipped[0].OtherHost).Should(gomega.BeTrue())
+	g.Expect(skipped[0].Reason).Should(gomega.Equal(`not intended for host "nas-main" (only laptop)`))
+
+	selected, skipped = FilterProfiles(all, ProfileFilter{Host: "nas-backup", OnlyActive: true})
+	g.Expect(selected).Should(gomega.Equal([]*ProfileConfiguration{anywhere}))
+	g.Expect(skipped[0].Reason).Should(gomega.Equal(`host "nas-backup" is excluded (nas-backup)`))
+
+	// The host takes precedence over other criteria, so that such profiles are never reported otherwise
+	selected, skipped = FilterProfiles(all, ProfileFilter{Host: "laptop", OnlyActive: true})
+	g.Expect(selected).Should(gomega.BeEmpty())
+	g.Expect(skipped).Should(gomega.HaveLen(3))
+	g.Expect(skipped[0].OtherHost).Should(gomega.BeTrue())
+	g.Expect(skipped[1].OtherHost).Should(gomega.BeTrue())
+	g.Expect(skipped[2].OtherHost).Should(gomega.BeFalse())
+	g.Expect(skipped[2].Reason).Should(gomega.Equal("not active"))
+}
+
+func TestSelectExpression(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	profile := newTestProfile(map[string]interface{}{
+		"name":   "scratch-1",
+		"active": true,
+		"tags":   []interface{}{"nightly"},
+		"source": "/srv/scratch",
+	})
+
+	for expression, expected := range map[string]bool{
+		"tag:nightly":                                   true,
+		"tag:nightly and not name:scratch-*":            false,
+		"tag:weekly or name:scratch-*":                  true,
+		"!(tag:weekly or source:/srv) or active:true":   true,
+		"not tag:nightly or tag:weekly and active:true": false,
+		`name:"scratch-1"`:                              true,
+		"NOT active:false AND repo:/backup":             false,
+	} {
+		selectExpression, err := ParseSelectExpression(expression)
+		g.Expect(err).ShouldNot(gomega.HaveOccurred(), expression)
+
+		match, reason := selectExpression.Evaluate(profile)
+		g.Expect(match).Should(gomega.Equal(expected), expression)
+		if !match {
+			g.Expect(reason).ShouldNot(gomega.BeEmpty(), expression)
+		}
+	}
+
+	selectExpression, err := ParseSelectExpression("tag:nightly and not (name:scratch-* or tag:x)")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(selectExpression.String()).Should(gomega.Equal("tag:nightly and not (name:scratch-* or tag:x)"))
+
+	_, reason := profile.MatchesFilter(ProfileFilter{Select: selectExpression})
+	g.Expect(reason).Should(gomega.Equal(`does not satisfy "tag:nightly and not (name:scratch-* or tag:x)": excluded by "not (name:scratch-* or tag:x)"`))
+
+	for _, invalid := range []string{"", "tag:", "colour:red", "tag:a and", "(tag:a", "tag:a)", `name:"open`, "tag:a tag:b"} {
+		_, err := ParseSelectExpression(invalid)
+		g.Expect(err).Should(gomega.HaveOccurred(), invalid)
+	}
+}
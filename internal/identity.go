@@ -0,0 +1,126 @@
+package resticmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RepositoryIdentity encapsulates the identities (restic repository IDs) of
+// the repositories accepted at the location of a profile repository, e.g., a
+// set of rotating disks mounted at the same path.
+type RepositoryIdentity struct {
+	// Members holds the accepted repository IDs, the first-recorded first.
+	Members []string
+	// LastBackup holds the time of the most-recent backup to each member.
+	LastBackup map[string]time.Time
+}
+
+// RotationMember encapsulates the backup status of a single accepted repository.
+type RotationMember struct {
+	ID         string
+	LastBackup time.Time
+	Overdue    bool
+}
+
+// parseRepositoryID parses the output of "restic cat config", returning the repository ID.
+func parseRepositoryID(output string) (string, error) {
+
+	var config struct {
+		ID string `json:"id"`
+	}
+
+	start := strings.Index(output, "{")
+	if start < 0 {
+		return "", fmt.Errorf("Could not parse repository config")
+	}
+	if err := json.Unmarshal([]byte(output[start:]), &config); err != nil {
+		return "", fmt.Errorf("Could not parse repository config: %v", err)
+	}
+	if config.ID == "" {
+		return "", fmt.Errorf("Repository config has no ID")
+	}
+
+	return config.ID, nil
+}
+
+// Known returns true if any repository ID has been accepted (or configured).
+func (identity *RepositoryIdentity) Known(configured []string) bool {
+
+	return len(identity.Members) > 0 || len(configured) > 0
+}
+
+// Accept accepts a repository ID if it is a member, or a configured (rotation)
+// member; the first ID is accepted (and recorded) if there are neither. Otherwise,
+// it is refused, e.g., as a swapped disk.
+func (identity *RepositoryIdentity) Accept(id string, configured []string) error {
+
+	if identity.Known(configured) && !containsString(identity.Members, id) && !containsString(configured, id) {
+		members := append(append([]string{}, identity.Members...), configured...)
+		return fmt.Errorf("repository ID %s is not an accepted member (%s); if the repository is a new rotation member, add it to repository-identity.ids",
+			id, strings.Join(members, ", "))
+	}
+
+	if !containsString(identity.Members, id) {
+		identity.Members = append(identity.Members, id)
+	}
+
+	return nil
+}
+
+// RecordBackup records a backup to the member with the specified ID.
+func (identity *RepositoryIdentity) RecordBackup(id string, when time.Time) {
+
+	if identity.LastBackup == nil {
+		identity.LastBackup = make(map[string]time.Time)
+	}
+
+	identity.LastBackup[id] = when
+}
+
+// Rotation returns the backup status of each member (recorded or configured),
+// as of now; a member is overdue if it has not been backed up within overdueAfter (if positive).
+func (identity *RepositoryIdentity) Rotation(configured []string, overdueAfter time.Duration, now time.Time) []RotationMember {
+
+	ids := append([]string{}, identity.Members...)
+	for _, id := range configured {
+		if !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	members := make([]RotationMember, 0, len(ids))
+	for _, id := range ids {
+		member := RotationMember{ID: id, LastBackup: identity.LastBackup[id]}
+		member.Overdue = overdueAfter > 0 && (member.LastBackup.IsZero() || now.Sub(member.LastBackup) > overdueAfter)
+		members = append(members, member)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].LastBackup.After(members[j].LastBackup)
+	})
+
+	return members
+}
+
+// SaveRepositoryIdentity records the accepted repository identities of a profile.
+func (appConfig *AppConfiguration) SaveRepositoryIdentity(profileName string, identity *RepositoryIdentity) error {
+
+	return appConfig.saveState("repositories", profileName, identity)
+}
+
+// LoadRepositoryIdentity retrieves the accepted repository identities of a profile
+// (none if they have never been recorded).
+func (appConfig *AppConfiguration) LoadRepositoryIdentity(profileName string) (*RepositoryIdentity, error) {
+
+	identity := &RepositoryIdentity{}
+
+	if err := appConfig.loadState("repositories", profileName, identity); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return identity, nil
+}
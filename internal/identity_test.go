@@ -0,0 +1,57 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestRepositoryIdentity(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	id, err := parseRepositoryID(`{"version":2,"id":"5d6c0a2e","chunker_polynomial":"3c657535c4d6f5"}`)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(id).Should(gomega.Equal("5d6c0a2e"))
+	_, err = parseRepositoryID(`{"version":2}`)
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	// The first repository is pinned; others are refused unless configured
+	identity := &RepositoryIdentity{}
+	g.Expect(identity.Known(nil)).Should(gomega.BeFalse())
+	g.Expect(identity.Accept("disk-a", nil)).Should(gomega.Succeed())
+	g.Expect(identity.Known(nil)).Should(gomega.BeTrue())
+	g.Expect(identity.Accept("disk-a", nil)).Should(gomega.Succeed())
+	g.Expect(identity.Accept("disk-b", nil)).Should(gomega.MatchError(gomega.ContainSubstring("disk-b is not an accepted member (disk-a)")))
+	g.Expect(identity.Accept("disk-b", []string{"disk-b", "disk-c"})).Should(gomega.Succeed())
+	g.Expect(identity.Members).Should(gomega.Equal([]string{"disk-a", "disk-b"}))
+
+	// With configured members, an unknown first repository is refused
+	g.Expect((&RepositoryIdentity{}).Accept("disk-x", []string{"disk-b"})).ShouldNot(gomega.Succeed())
+
+	now := time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC)
+	identity.RecordBackup("disk-a", now.AddDate(0, 0, -1))
+	identity.RecordBackup("disk-b", now.AddDate(0, 0, -10))
+
+	g.Expect(identity.Rotation([]string{"disk-b", "disk-c"}, 7*24*time.Hour, now)).Should(gomega.Equal([]RotationMember{
+		{ID: "disk-a", LastBackup: now.AddDate(0, 0, -1)},
+		{ID: "disk-b", LastBackup: now.AddDate(0, 0, -10), Overdue: true},
+		{ID: "disk-c", Overdue: true},
+	}))
+	g.Expect(identity.Rotation(nil, 0, now)[1].Overdue).Should(gomega.BeFalse())
+
+	directory, err := ioutil.TempDir("", "identity")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	config := NewAppConfiguration()
+	config.viper.Set("state-dir", directory)
+	g.Expect(config.SaveRepositoryIdentity("usb", identity)).Should(gomega.Succeed())
+	loaded, err := config.LoadRepositoryIdentity("usb")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(loaded.Members).Should(gomega.Equal(identity.Members))
+	g.Expect(loaded.LastBackup["disk-b"].Equal(identity.LastBackup["disk-b"])).Should(gomega.BeTrue())
+}
@@ -2,33 +2,52 @@ package resticmanager
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
-	"html/template"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
+	"github.com/go-mail/mail"
 	"github.com/i-am-david-fernandez/glog"
 )
 
+// MailAttachment encapsulates a file attached to an email message.
+type MailAttachment struct {
+	Name    string
+	Content []byte
+}
+
 // MailMessage encapsulates an email message.
 type MailMessage struct {
-	Sender     string
-	Recipients []string
-	Subject    string
-	content    string
+	Sender      string
+	Recipients  []string
+	Subject     string
+	Attachments []MailAttachment
+	content     string
+	textContent string
 }
 
 // NewMailMessage returns a new MailMessage.
 func NewMailMessage() *MailMessage {
 
 	return &MailMessage{
-		Recipients: make([]string, 0),
-		content:    "",
+		Recipients:  make([]string, 0),
+		Attachments: make([]MailAttachment, 0),
+		content:     "",
+		textContent: "",
 	}
 }
 
-// Content returns the message content.
+// Content returns the message (html) content, with secrets redacted (see RedactString).
 func (message *MailMessage) Content() string {
-	return message.content
+	return RedactString(message.content)
+}
+
+// TextContent returns the message plain-text content, with secrets redacted (see RedactString).
+func (message *MailMessage) TextContent() string {
+	return RedactString(message.textContent)
 }
 
 // AddRecipients adds the specified set of recipients to the message 'To' header.
@@ -50,22 +69,122 @@ func (message *MailMessage) AddContent(content string) {
 	message.content += content
 }
 
+// AddTextContent adds content to the message plain-text content.
+func (message *MailMessage) AddTextContent(content string) {
+	message.textContent += content
+}
+
 // AddTemplatedContent adds templated content to the message.
 func (message *MailMessage) AddTemplatedContent(templateDefinition string, data interface{}) {
 
-	templateEngine, err := template.New("message").Parse(templateDefinition)
+	message.AddLayeredTemplatedContent([]string{templateDefinition}, data)
+}
+
+// AddLayeredTemplatedContent adds content to the message, rendered from a sequence of template layers.
+func (message *MailMessage) AddLayeredTemplatedContent(templateLayers []string, data interface{}) {
+
+	content, err := ExecuteTemplateLayers(templateLayers, data)
 	if err != nil {
-		glog.Errorf("Could not parse message template: %v", err)
+		glog.Errorf("Could not render message template: %v", err)
 		return
 	}
 
-	var buffer bytes.Buffer
+	message.content += content
+}
+
+// AddTemplatedTextContent adds templated content to the message plain-text content.
+func (message *MailMessage) AddTemplatedTextContent(templateDefinition string, data interface{}) {
+
+	message.AddLayeredTemplatedTextContent([]string{templateDefinition}, data)
+}
+
+// AddLayeredTemplatedTextContent adds plain-text content to the message, rendered from a sequence of template layers.
+func (message *MailMessage) AddLayeredTemplatedTextContent(templateLayers []string, data interface{}) {
 
-	err = templateEngine.Execute(&buffer, data)
+	content, err := ExecuteTextTemplateLayers(templateLayers, data)
 	if err != nil {
-		glog.Errorf("Could not execute message template: %v", err)
+		glog.Errorf("Could not render message text template: %v", err)
 		return
 	}
 
-	message.content += buffer.String()
+	message.textContent += content
+}
+
+// Attach adds an attachment to the message. Content larger than compressAbove
+// bytes (if positive) is gzip-compressed.
+func (message *MailMessage) Attach(name string, content []byte, compressAbove int64) {
+
+	content = []byte(RedactString(string(content)))
+
+	if compressAbove > 0 && int64(len(content)) > compressAbove {
+
+		var buffer bytes.Buffer
+
+		writer := gzip.NewWriter(&buffer)
+		writer.Name = name
+		_, err := writer.Write(content)
+		if err == nil {
+			err = writer.Close()
+		}
+
+		if err != nil {
+			glog.Errorf("Could not compress attachment %s: %v", name, err)
+		} else {
+			name += ".gz"
+			content = buffer.Bytes()
+		}
+	}
+
+	message.Attachments = append(message.Attachments, MailAttachment{
+		Name:    name,
+		Content: content,
+	})
+}
+
+// AttachFile adds a file as an attachment to the message. Files larger than
+// compressAbove bytes (if positive) are gzip-compressed.
+func (message *MailMessage) AttachFile(filename string, compressAbove int64) error {
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Could not read attachment %s: %v", filename, err)
+	}
+
+	message.Attach(filepath.Base(filename), content, compressAbove)
+
+	return nil
+}
+
+// compose builds a sendable message, using a multipart/alternative body if
+// plain-text content is present. Secrets are redacted (see RedactString).
+func (message *MailMessage) compose() *mail.Message {
+
+	m := mail.NewMessage()
+
+	m.SetHeader("To", message.Recipients...)
+	m.SetHeader("From", message.Sender)
+	m.SetHeader("Subject", RedactString(message.Subject))
+
+	content := message.Content()
+	textContent := message.TextContent()
+
+	if textContent != "" {
+		m.SetBody("text/plain", textContent)
+		if content != "" {
+			m.AddAlternative("text/html", content)
+		}
+	} else {
+		m.SetBody("text/html", content)
+	}
+
+	for _, attachment := range message.Attachments {
+		m.AttachReader(attachment.Name, bytes.NewReader(attachment.Content))
+	}
+
+	return m
+}
+
+// WriteTo writes the message, in RFC 5322 (.eml) format, to w.
+func (message *MailMessage) WriteTo(w io.Writer) (int64, error) {
+	return message.compose().WriteTo(w)
 }
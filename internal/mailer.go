@@ -2,7 +2,6 @@ package resticmanager
 
 import (
 	"github.com/go-mail/mail"
-	"github.com/i-am-david-fernandez/glog"
 )
 
 type _SmtpConfig struct {
@@ -25,14 +24,20 @@ func NewMailer() *Mailer {
 	return mailer
 }
 
-func (mailer *Mailer) SendMail(sender string, recipients []string, subject string, content string) {
+// SendMail sends a single (html) e-mail, returning any delivery error.
+func (mailer *Mailer) SendMail(sender string, recipients []string, subject string, content string) error {
 
-	m := mail.NewMessage()
+	message := NewMailMessage()
+	message.Sender = sender
+	message.AddRecipients(recipients...)
+	message.Subject = subject
+	message.AddContent(content)
 
-	m.SetHeader("To", recipients...)
-	m.SetHeader("From", sender)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", content)
+	return mailer.SendMessage(message)
+}
+
+// SendMessage sends a MailMessage, returning any delivery error.
+func (mailer *Mailer) SendMessage(message *MailMessage) error {
 
 	d := mail.NewDialer(
 		mailer.SMTP.Host,
@@ -46,17 +51,5 @@ func (mailer *Mailer) SendMail(sender string, recipients []string, subject strin
 	}
 
 	// Send the email
-	if err := d.DialAndSend(m); err != nil {
-		glog.Errorf("Could not send mail: %s", err)
-	}
-}
-
-func (mailer *Mailer) SendMessage(message *MailMessage) {
-
-	mailer.SendMail(
-		message.Sender,
-		message.Recipients,
-		message.Subject,
-		message.Content(),
-	)
+	return d.DialAndSend(message.compose())
 }
@@ -0,0 +1,241 @@
+package resticmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	"github.com/spf13/viper"
+)
+
+// matrixKey is the profile key defining a profile matrix. A matrix expands a
+// single profile file into one profile per matrix value, e.g.:
+//
+//	matrix:
+//	  glob: /home/*
+//	  name: "home-{{.matrix.base}}"
+//	source: "{{.matrix.value}}"
+//	repo: "/backup/{{.matrix.base}}"
+//
+// Values are taken from exactly one of a list ("values"), the directories
+// matching a pattern ("glob") or the lines output by a shell command
+// ("command"; run whenever profiles are loaded, unless matrix commands are
+// disabled, in which case the matrix generates no profiles). Each generated
+// profile is expanded with the additional template context .matrix.value,
+// .matrix.base (the final path element of the value) and .matrix.index. Generated profiles are named per the "name" template
+// (by default, "<name>-<base>", where name defaults to the file base name).
+const matrixKey = "matrix"
+
+// defaultMatrixName is the template used to derive the name of a generated profile.
+const defaultMatrixName = "{{.name}}-{{.matrix.base}}"
+
+// ProfileMatrix encapsulates a profile matrix definition.
+type ProfileMatrix struct {
+	Values  []string
+	Glob    string
+	Command string
+	Name    string
+}
+
+// newProfileMatrix creates a ProfileMatrix from (unexpanded) settings, expanding
+// its glob and command against the profile settings (without matrix context).
+func newProfileMatrix(settings map[string]interface{}) (*ProfileMatrix, error) {
+
+	spec, ok := settings[matrixKey].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("matrix must be a map")
+	}
+
+	v := viper.New()
+	v.MergeConfigMap(spec)
+
+	matrix := &ProfileMatrix{
+		Values:  v.GetStringSlice("values"),
+		Glob:    v.GetString("glob"),
+		Command: v.GetString("command"),
+		Name:    v.GetString("name"),
+	}
+
+	sources := 0
+	for _, set := range []bool{v.IsSet("values"), matrix.Glob != "", matrix.Command != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("matrix must define exactly one of values, glob or command")
+	}
+
+	if matrix.Name == "" {
+		matrix.Name = defaultMatrixName
+	}
+
+	context := expansionContext(settings, nil)
+	functions := ExpansionFunctions(time.Now())
+
+	var err error
+	if matrix.Glob, err = expandString(matrix.Glob, context, functions); err != nil {
+		return nil, fmt.Errorf("Could not expand matrix glob: %v", err)
+	}
+	if matrix.Command, err = expandString(matrix.Command, context, functions); err != nil {
+		return nil, fmt.Errorf("Could not expand matrix command: %v", err)
+	}
+
+	return matrix, nil
+}
+
+// Resolve returns the matrix values.
+func (matrix *ProfileMatrix) Resolve() ([]string, error) {
+
+	switch {
+
+	case matrix.Glob != "":
+		matches, err := filepath.Glob(matrix.Glob)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid matrix glob %q: %v", matrix.Glob, err)
+		}
+		values := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if stat, err := os.Stat(match); err == nil && stat.IsDir() {
+				values = append(values, match)
+			}
+		}
+		sort.Strings(values)
+		return values, nil
+
+	case matrix.Command != "":
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", matrix.Command)
+		} else {
+			cmd = exec.Command("sh", "-c", matrix.Command)
+		}
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("Matrix command %q failed: %v", matrix.Command, err)
+		}
+		values := make([]string, 0)
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				values = append(values, line)
+			}
+		}
+		return values, nil
+	}
+
+	return matrix.Values, nil
+}
+
+// matrixContext returns the template context of a single matrix value.
+func matrixContext(value string, index int) map[string]interface{} {
+
+	return map[string]interface{}{
+		"value": value,
+		"base":  filepath.Base(value),
+		"index": index,
+	}
+}
+
+// LoadProfileConfigurations loads a profile file, returning a single profile
+// or, for a profile defining a matrix, one profile per matrix value. Any
+// profiles that could be loaded are returned, even if an error occurs. If
+// runCommands is false, a matrix defined by a command generates no profiles
+// (e.g., for application commands that do not act on profiles, so that these
+// have no side effects).
+func LoadProfileConfigurations(filename string, defaults map[string]interface{}, runCommands bool) ([]*ProfileConfiguration, error) {
+
+	template := NewProfileConfiguration()
+	template.SetDefaults(defaults)
+
+	settings, err := template.loadSettings(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := settings[matrixKey]; !ok {
+		if err := template.applySettings(settings, nil); err != nil {
+			return nil, err
+		}
+		return []*ProfileConfiguration{template}, nil
+	}
+
+	matrix, err := newProfileMatrix(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if matrix.Command != "" && !runCommands {
+		glog.Infof("Profile matrix %v not expanded (its command is only run for commands acting on profiles).", filename)
+		return nil, nil
+	}
+
+	values, err := matrix.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		glog.Warningf("Profile matrix %v has no values; no profiles generated.", filename)
+	}
+
+	profiles := make([]*ProfileConfiguration, 0, len(values))
+	errs := make([]error, 0)
+	functions := ExpansionFunctions(time.Now())
+
+	for i, value := range values {
+
+		profile := NewProfileConfiguration()
+		profile.SetDefaults(defaults)
+		profile.viper.SetConfigFile(filename)
+		profile.raw = template.raw
+		profile.issues = template.issues
+		for k, v := range template.origins {
+			profile.origins[k] = v
+		}
+		profile.matrix = matrixContext(value, i)
+
+		generated := copySettings(settings)
+		delete(generated, matrixKey)
+		extra := map[string]interface{}{matrixKey: profile.matrix}
+
+		// Derive the profile name from the (expanded) base name, which defaults to the file name
+		baseName, ok := generated["name"].(string)
+		if !ok || baseName == "" {
+			baseName = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		}
+		name, err := expandString(baseName, expansionContext(generated, extra), functions)
+		if err == nil {
+			generated["name"] = name
+			name, err = expandString(matrix.Name, expansionContext(generated, extra), functions)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Could not derive name of matrix profile %q: %v", value, err))
+			continue
+		}
+		generated["name"] = name
+		profile.origins["name"] = filename + " (matrix)"
+
+		if err := profile.applySettings(generated, extra); err != nil {
+			errs = append(errs, fmt.Errorf("Matrix profile %q: %v", value, err))
+			continue
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	if len(errs) > 0 {
+		for _, err := range errs[1:] {
+			glog.Errorf("%v", err)
+		}
+		return profiles, errs[0]
+	}
+
+	return profiles, nil
+}
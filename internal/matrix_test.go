@@ -0,0 +1,102 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestProfileMatrix(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "matrix")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	for _, name := range []string{"alice", "bob"} {
+		g.Expect(os.MkdirAll(filepath.Join(directory, "home", name), 0700)).Should(gomega.Succeed())
+	}
+	g.Expect(ioutil.WriteFile(filepath.Join(directory, "home", "file"), []byte{}, 0600)).Should(gomega.Succeed())
+
+	write := func(name string, content string) string {
+		filename := filepath.Join(directory, name)
+		g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+		return filename
+	}
+
+	defaults := map[string]interface{}{
+		"logging": map[string]interface{}{"file": "{{.repo}}.log"},
+	}
+
+	// Glob (directories only), with the default name
+	globFile := write("glob.yml", "matrix:\n  glob: "+filepath.Join(directory, "home", "*")+"\nname: home\nsource: '{{.matrix.value}}'\nrepo: '/backup/{{.matrix.base}}'\n")
+
+	profiles, err := LoadProfileConfigurations(globFile, defaults, true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.HaveLen(2))
+	g.Expect(profiles[0].Name()).Should(gomega.Equal("home-alice"))
+	g.Expect(profiles[0].Source()).Should(gomega.Equal(filepath.Join(directory, "home", "alice")))
+	g.Expect(profiles[1].Repository()).Should(gomega.Equal("/backup/bob"))
+	g.Expect(profiles[1].LogFile()).Should(gomega.Equal("/backup/bob.log"))
+	g.Expect(profiles[1].MatrixValue()).Should(gomega.Equal(filepath.Join(directory, "home", "bob")))
+
+	// Values, with a name template
+	valuesFile := write("values.yml", "matrix:\n  values: [red, green, blue]\n  name: 'p{{.matrix.index}}-{{.matrix.value}}'\nname: base\n")
+
+	profiles, err = LoadProfileConfigurations(valuesFile, nil, true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.HaveLen(3))
+	g.Expect(profiles[2].Name()).Should(gomega.Equal("p2-blue"))
+
+	// Passwords are expanded, e.g., from the environment (as in the sample matrix)
+	os.Setenv("RESTIC_HOME_PASSWORD", "hunter2")
+	defer os.Unsetenv("RESTIC_HOME_PASSWORD")
+	sample, err := ioutil.ReadFile(filepath.Join("..", "sample-config", "profiles", "homes.example.yml"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	sampleFile := write("homes.yml", strings.Replace(string(sample), "glob: /home/*", "values: [/home/alice]", 1))
+
+	profiles, err = LoadProfileConfigurations(sampleFile, nil, true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.HaveLen(1))
+	g.Expect(profiles[0].Password()).Should(gomega.Equal("hunter2"))
+
+	// Command
+	commandFile := write("command.yml", "matrix:\n  command: 'echo one; echo; echo two'\nname: cmd\n")
+
+	profiles, err = LoadProfileConfigurations(commandFile, nil, true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.HaveLen(2))
+	g.Expect(profiles[1].Name()).Should(gomega.Equal("cmd-two"))
+
+	// Commands are not run for application commands not acting on profiles
+	profiles, err = LoadProfileConfigurations(commandFile, nil, false)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.BeEmpty())
+
+	// The base name defaults to the file name
+	unnamedFile := write("unnamed.yml", "matrix:\n  values: [a]\n")
+	profiles, err = LoadProfileConfigurations(unnamedFile, nil, true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profiles).Should(gomega.HaveLen(1))
+	g.Expect(profiles[0].Name()).Should(gomega.Equal("unnamed-a"))
+
+	// A matrix must have exactly one source of values
+	invalidFile := write("invalid.yml", "matrix:\n  values: [a]\n  glob: '*'\nname: invalid\n")
+	_, err = LoadProfileConfigurations(invalidFile, nil, true)
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	// A matrix profile cannot be loaded as a single profile
+	_, err = LoadProfileConfiguration(valuesFile)
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	// Generated profiles are subject to filtering like any other
+	filter := ProfileFilter{Names: []string{"p1-green"}}
+	selected, errs := LoadProfiles([]string{valuesFile, globFile}, filter, nil)
+	g.Expect(errs).Should(gomega.BeEmpty())
+	g.Expect(selected).Should(gomega.HaveLen(1))
+}
@@ -0,0 +1,396 @@
+package resticmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Merge directives. A directive is appended to a key to alter how its value
+// is merged with an inherited (e.g., profile-default) value:
+//
+//	key:  nested maps are merged key-by-key; lists and scalars replace the inherited value
+//	key+: list items are appended to the inherited list
+//	key!: the value replaces the inherited value entirely (i.e., maps are not merged)
+const (
+	mergeAppendSuffix  = "+"
+	mergeReplaceSuffix = "!"
+)
+
+// OriginDefaults is the origin recorded for values inherited from the application profile-defaults.
+const OriginDefaults = "profile-defaults"
+
+// readSettings reads a configuration file (of any format supported by viper) into a settings map.
+func readSettings(filename string) (map[string]interface{}, error) {
+
+	v := viper.New()
+	v.SetConfigFile(filename)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	return v.AllSettings(), nil
+}
+
+// includeKey is the profile key listing fragment files to be merged before the profile's own keys.
+const includeKey = "include"
+
+// includeList returns the list of included fragment files declared in settings.
+func includeList(settings map[string]interface{}) ([]string, error) {
+
+	value, ok := settings[includeKey]
+	if !ok {
+		return nil, nil
+	}
+
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+
+	items, ok := toList(value)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of files", includeKey)
+	}
+
+	includes := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of files", includeKey)
+		}
+		includes[i] = s
+	}
+
+	return includes, nil
+}
+
+// mergeProfileFile reads a profile (or fragment) file and merges it into
+// settings. Any files listed under the "include" key are resolved relative to
+// the including file and merged, in order (and recursively), before the file's
+// own keys. chain holds the files currently being included, for cycle detection.
+// visit (if not nil) is called with the name of each file read. The content of
+// the file itself is returned.
+func mergeProfileFile(settings map[string]interface{}, filename string, origins map[string]string, chain []string, visit func(string)) (map[string]interface{}, error) {
+
+	absolute, err := filepath.Abs(filename)
+	if err != nil {
+		absolute = filename
+	}
+
+	for _, including := range chain {
+		if including == absolute {
+			return nil, fmt.Errorf("Include cycle detected: %s -> %s", strings.Join(chain, " -> "), absolute)
+		}
+	}
+	chain = append(chain, absolute)
+
+	raw, err := readSettings(filename)
+	if err != nil {
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("Could not read included fragment %v (from %v): %v", filename, chain[len(chain)-2], err)
+		}
+		return nil, fmt.Errorf("Could not read profile from %v: %v", filename, err)
+	}
+
+	if visit != nil {
+		visit(filename)
+	}
+
+	includes, err := includeList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid include in %v: %v", filename, err)
+	}
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		if _, err := mergeProfileFile(settings, include, origins, chain, visit); err != nil {
+			return nil, err
+		}
+	}
+
+	own := copySettings(raw)
+	delete(own, includeKey)
+
+	if err := mergeSettings(settings, own, filename, origins, ""); err != nil {
+		return nil, fmt.Errorf("Could not merge %v: %v", filename, err)
+	}
+
+	return raw, nil
+}
+
+// copySettings returns a deep copy of a settings map.
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+
+	result := make(map[string]interface{}, len(settings))
+
+	for k, v := range settings {
+		result[k] = copySettingsValue(v)
+	}
+
+	return result
+}
+
+func copySettingsValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copySettings(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = copySettingsValue(item)
+		}
+		return items
+	case []string:
+		return append([]string(nil), v...)
+	}
+
+	return value
+}
+
+// toList converts a list-like settings value to a []interface{}.
+func toList(value interface{}) ([]interface{}, bool) {
+
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, true
+	case nil:
+		return []interface{}{}, true
+	}
+
+	return nil, false
+}
+
+func joinKey(prefix string, key string) string {
+
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
+
+// clearOrigins forgets any recorded origins at or beneath a key.
+func clearOrigins(origins map[string]string, key string) {
+
+	for k := range origins {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(origins, k)
+		}
+	}
+}
+
+// setOrigins records the origin of a value (and, for a map, of each of its leaf values).
+func setOrigins(origins map[string]string, key string, value interface{}, origin string) {
+
+	if origins == nil {
+		return
+	}
+
+	clearOrigins(origins, key)
+
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for k, v := range m {
+			setOrigins(origins, joinKey(key, k), v, origin)
+		}
+		return
+	}
+
+	origins[key] = origin
+}
+
+// mergeSettings merges overlay into base (modifying base), honouring merge
+// directives and recording the origin of each leaf value in origins (if not nil).
+func mergeSettings(base map[string]interface{}, overlay map[string]interface{}, origin string, origins map[string]string, prefix string) error {
+
+	// Process keys in a stable order so that, e.g., "key" is merged before "key+".
+	keys := make([]string, 0, len(overlay))
+	for k := range overlay {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, rawKey := range keys {
+
+		value := copySettingsValue(overlay[rawKey])
+
+		switch {
+
+		case strings.HasSuffix(rawKey, mergeAppendSuffix):
+
+			key := strings.TrimSuffix(rawKey, mergeAppendSuffix)
+			fullKey := joinKey(prefix, key)
+
+			items, ok := toList(value)
+			if !ok {
+				return fmt.Errorf("Cannot append non-list value to %s", fullKey)
+			}
+
+			existing, ok := toList(base[key])
+			if !ok {
+				return fmt.Errorf("Cannot append to non-list value %s", fullKey)
+			}
+
+			base[key] = append(append([]interface{}{}, existing...), items...)
+
+			if origins != nil {
+				if previous, ok := origins[fullKey]; ok && previous != origin && len(existing) > 0 {
+					origins[fullKey] = previous + " + " + origin
+				} else {
+					origins[fullKey] = origin
+				}
+			}
+
+		case strings.HasSuffix(rawKey, mergeReplaceSuffix):
+
+			key := strings.TrimSuffix(rawKey, mergeReplaceSuffix)
+			fullKey := joinKey(prefix, key)
+
+			// Normalise (i.e., process any directives within) the replacement value
+			if m, ok := value.(map[string]interface{}); ok {
+				normalised := make(map[string]interface{})
+				if err := mergeSettings(normalised, m, origin, nil, fullKey); err != nil {
+					return err
+				}
+				value = normalised
+			}
+
+			base[key] = value
+			setOrigins(origins, fullKey, value, origin)
+
+		default:
+
+			key := rawKey
+			fullKey := joinKey(prefix, key)
+
+			overlayMap, overlayIsMap := value.(map[string]interface{})
+			baseMap, baseIsMap := base[key].(map[string]interface{})
+
+			if overlayIsMap {
+				if !baseIsMap {
+					baseMap = make(map[string]interface{})
+					clearOrigins(origins, fullKey)
+				}
+				if err := mergeSettings(baseMap, overlayMap, origin, origins, fullKey); err != nil {
+					return err
+				}
+				base[key] = baseMap
+			} else {
+				base[key] = value
+				setOrigins(origins, fullKey, value, origin)
+			}
+		}
+	}
+
+	return nil
+}
+
+// flattenSettings returns a flat map of dotted keys to leaf values.
+func flattenSettings(settings map[string]interface{}, prefix string, flat map[string]interface{}) map[string]interface{} {
+
+	if flat == nil {
+		flat = make(map[string]interface{})
+	}
+
+	for k, v := range settings {
+		key := joinKey(prefix, k)
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			flattenSettings(m, key, flat)
+		} else {
+			flat[key] = v
+		}
+	}
+
+	return flat
+}
+
+// flowString returns a compact, single-line (YAML flow-style) representation of a settings value.
+func flowString(value interface{}) string {
+
+	switch v := value.(type) {
+
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = flowString(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = flowString(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for k, item := range v {
+			items = append(items, fmt.Sprintf("%s: %s", k, flowString(item)))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+
+	case map[interface{}]interface{}:
+		items := make([]string, 0, len(v))
+		for k, item := range v {
+			items = append(items, fmt.Sprintf("%v: %s", k, flowString(item)))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// annotateSettings returns a flat, sorted, "key: value  # origin" representation of settings.
+func annotateSettings(settings map[string]interface{}, origins map[string]string) string {
+
+	const maxWidth = 60
+
+	flat := flattenSettings(settings, "", nil)
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, len(keys))
+	width := 0
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("%s: %s", k, flowString(flat[k]))
+		if len(lines[i]) > width && len(lines[i]) <= maxWidth {
+			width = len(lines[i])
+		}
+	}
+
+	var builder strings.Builder
+	for i, k := range keys {
+		origin := origins[k]
+		if origin == "" {
+			origin = "(unknown)"
+		}
+		builder.WriteString(fmt.Sprintf("%-*s  # %s\n", width, lines[i], origin))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,158 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestMergeSettings(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	defaults := map[string]interface{}{
+		"active": false,
+		"email": map[string]interface{}{
+			"level":      "info",
+			"recipients": []interface{}{"default@example.com"},
+		},
+		"exclusions": []interface{}{"*.tmp"},
+		"logging": map[string]interface{}{
+			"level":  "debug",
+			"append": true,
+		},
+	}
+
+	profile := map[string]interface{}{
+		"active": true,
+		"email": map[string]interface{}{
+			"recipients": []interface{}{"profile@example.com"},
+		},
+		"exclusions+": []interface{}{"*.bak"},
+		"logging!": map[string]interface{}{
+			"level": "warning",
+		},
+	}
+
+	settings := make(map[string]interface{})
+	origins := make(map[string]string)
+
+	g.Expect(mergeSettings(settings, defaults, OriginDefaults, origins, "")).Should(gomega.Succeed())
+	g.Expect(mergeSettings(settings, profile, "profile.yml", origins, "")).Should(gomega.Succeed())
+
+	g.Expect(settings["active"]).Should(gomega.Equal(true))
+
+	// Nested maps are merged key-by-key
+	email := settings["email"].(map[string]interface{})
+	g.Expect(email["level"]).Should(gomega.Equal("info"))
+	g.Expect(email["recipients"]).Should(gomega.Equal([]interface{}{"profile@example.com"}))
+
+	// key+ appends
+	g.Expect(settings["exclusions"]).Should(gomega.Equal([]interface{}{"*.tmp", "*.bak"}))
+
+	// key! replaces
+	g.Expect(settings["logging"]).Should(gomega.Equal(map[string]interface{}{"level": "warning"}))
+
+	// The defaults are not modified
+	g.Expect(defaults["exclusions"]).Should(gomega.Equal([]interface{}{"*.tmp"}))
+
+	g.Expect(origins).Should(gomega.Equal(map[string]string{
+		"active":           "profile.yml",
+		"email.level":      OriginDefaults,
+		"email.recipients": "profile.yml",
+		"exclusions":       OriginDefaults + " + profile.yml",
+		"logging.level":    "profile.yml",
+	}))
+
+	// Appending to a non-list value is an error
+	err := mergeSettings(settings, map[string]interface{}{"active+": []interface{}{"x"}}, "bad.yml", nil, "")
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestFlowString(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(flowString("text")).Should(gomega.Equal("text"))
+	g.Expect(flowString([]interface{}{"a", 1})).Should(gomega.Equal("[a, 1]"))
+	g.Expect(flowString([]interface{}{map[interface{}]interface{}{"warning": 1}})).Should(gomega.Equal("[{warning: 1}]"))
+}
+
+func TestProfileIncludes(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "include")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	write := func(name string, content string) string {
+		filename := filepath.Join(directory, name)
+		g.Expect(os.MkdirAll(filepath.Dir(filename), 0700)).Should(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+		return filename
+	}
+
+	write("common/base.yml", "exclusions: ['*.tmp']\nactive: false\n")
+	write("common/extra.yml", "include: [base.yml]\nexclusions+: ['*.bak']\n")
+	profileFile := write("profile.yml", "include: [common/extra.yml]\nname: test\nactive: true\n")
+
+	profile, err := LoadProfileConfiguration(profileFile)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profile.IsActive()).Should(gomega.BeTrue())
+	g.Expect(profile.Exclusions()).Should(gomega.Equal([]string{"*.tmp", "*.bak"}))
+	g.Expect(profile.Includes()).Should(gomega.Equal([]string{"common/extra.yml"}))
+
+	// Missing fragments are reported
+	missingFile := write("missing.yml", "include: [common/nothing.yml]\nname: missing\n")
+	_, err = LoadProfileConfiguration(missingFile)
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	// As are include cycles
+	write("cycle-a.yml", "include: [cycle-b.yml]\n")
+	write("cycle-b.yml", "include: [cycle-a.yml]\n")
+	cycleFile := write("cycle.yml", "include: [cycle-a.yml]\nname: cycle\n")
+	_, err = LoadProfileConfiguration(cycleFile)
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("cycle")))
+
+	profiles, errs := LoadProfiles([]string{profileFile, missingFile, cycleFile}, *NewProfileFilter(), nil)
+	g.Expect(profiles).Should(gomega.HaveLen(1))
+	g.Expect(errs).Should(gomega.HaveLen(2))
+}
+
+func TestFindProfiles(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "profiles")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	for _, name := range []string{
+		"a.yml", "b.yaml", "c.json", "d.toml",
+		"notes.txt", ".hidden.yml", "e.yml~", "f.yml.bak", "#g.yml#",
+		"drafts/h.yml", "sub/i.yml", "sub/j.example.yml",
+	} {
+		filename := filepath.Join(directory, name)
+		g.Expect(os.MkdirAll(filepath.Dir(filename), 0700)).Should(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filename, []byte{}, 0600)).Should(gomega.Succeed())
+	}
+
+	ignore := "# comment\ndrafts/\n*.example.yml\n"
+	g.Expect(ioutil.WriteFile(filepath.Join(directory, ProfileIgnoreFile), []byte(ignore), 0600)).Should(gomega.Succeed())
+
+	profiles, errs := FindProfiles(directory)
+	g.Expect(errs).Should(gomega.BeEmpty())
+
+	relative := make([]string, len(profiles))
+	for i, profile := range profiles {
+		relative[i], _ = filepath.Rel(directory, profile)
+	}
+	g.Expect(relative).Should(gomega.ConsistOf("a.yml", "b.yaml", "c.json", "d.toml", "sub/i.yml"))
+
+	_, errs = FindProfiles(filepath.Join(directory, "nonexistent"))
+	g.Expect(errs).Should(gomega.HaveLen(1))
+}
@@ -0,0 +1,87 @@
+package resticmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// MigrationChange describes a single change made when migrating a configuration file.
+type MigrationChange struct {
+	File   string
+	Line   int
+	Column int
+	Key    string
+	Old    string
+	New    string
+}
+
+// String returns a representation of the change, e.g., "profile.yml:12:9: logging.file: <repo>.log -> {{.repo}}.log".
+func (change MigrationChange) String() string {
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s -> %s", change.File, change.Line, change.Column, change.Key, change.Old, change.New)
+}
+
+// MigrateFile migrates a (YAML) application or profile configuration file to
+// the current syntax, i.e., deprecated angle-bracket substitutions are replaced
+// with templates. The migrated content is returned along with the changes
+// made; the file itself is not modified. The layout and comments of the file
+// are preserved.
+func MigrateFile(filename string) ([]byte, []MigrationChange, error) {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+	default:
+		return nil, nil, fmt.Errorf("Migration of %v is not supported (only YAML files can be migrated)", filename)
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := parseYAMLDocument(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not parse %v: %v", filename, err)
+	}
+
+	edits := make([]yamlScalarEdit, 0)
+	changes := make([]MigrationChange, 0)
+
+	walkScalarValues(doc.root, "", func(key string, node *yamlv3.Node) {
+
+		if containsString(unexpandedKeys, strings.ToLower(strings.TrimPrefix(key, "profile-defaults."))) || isSecretKey(key) {
+			return
+		}
+
+		if !hasLegacySubstitution(node.Value) {
+			return
+		}
+
+		value := MigrateLegacySubstitutions(node.Value)
+
+		edits = append(edits, yamlScalarEdit{node: node, value: value})
+		changes = append(changes, MigrationChange{
+			File:   filename,
+			Line:   node.Line,
+			Column: node.Column,
+			Key:    key,
+			Old:    node.Value,
+			New:    value,
+		})
+	})
+
+	if len(edits) == 0 {
+		return content, changes, nil
+	}
+
+	migrated, err := doc.applyScalarEdits(edits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not migrate %v: %v", filename, err)
+	}
+
+	return migrated, changes, nil
+}
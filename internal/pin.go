@@ -0,0 +1,214 @@
+package resticmanager
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// PinTag is the snapshot tag marking a pinned snapshot. Retention policy
+// application always keeps snapshots with this tag, until the pin expires
+// (see applyPins).
+const PinTag = "pinned"
+
+// Prefixes of the snapshot tags recording the expiry date and note of a pin.
+const (
+	pinUntilPrefix = "pinned-until="
+	pinNotePrefix  = "pinned-note="
+)
+
+// PinDateFormat is the format of pin expiry dates.
+const PinDateFormat = "2006-01-02"
+
+// Pin encapsulates a pinned snapshot, along with its (optional) expiry date and note.
+type Pin struct {
+	Snapshot Snapshot
+	Until    time.Time
+	Note     string
+}
+
+// Expired returns true if the pin has an expiry date that has passed, as of now.
+// A pin expires at the end of its expiry date.
+func (pin Pin) Expired(now time.Time) bool {
+
+	return !pin.Until.IsZero() && !now.Before(pin.Until.AddDate(0, 0, 1))
+}
+
+// Expiry returns the pin expiry date, as text ("never" if there is none).
+func (pin Pin) Expiry() string {
+
+	if pin.Until.IsZero() {
+		return "never"
+	}
+
+	return pin.Until.Format(PinDateFormat)
+}
+
+// PinTags returns the snapshot tags recording a pin with the specified expiry
+// date (if not zero) and note (if not empty).
+func PinTags(until time.Time, note string) []string {
+
+	tags := []string{PinTag}
+
+	if !until.IsZero() {
+		tags = append(tags, pinUntilPrefix+until.Format(PinDateFormat))
+	}
+
+	if note = strings.TrimSpace(note); note != "" {
+		// restic separates tags with commas
+		tags = append(tags, pinNotePrefix+strings.Replace(note, ",", ";", -1))
+	}
+
+	return tags
+}
+
+// pinTagsOf returns those tags of a snapshot that record a pin.
+func pinTagsOf(snapshot Snapshot) []string {
+
+	tags := make([]string, 0)
+	for _, tag := range snapshot.Tags {
+		if tag == PinTag || strings.HasPrefix(tag, pinUntilPrefix) || strings.HasPrefix(tag, pinNotePrefix) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// pinTagChanges returns the tags to add to, and remove from, a snapshot to pin
+// it with the specified expiry date and note, replacing any existing pin. As
+// restic applies removals after additions, tags of the existing pin that are
+// retained (e.g., "pinned") are neither added nor removed.
+func pinTagChanges(snapshot Snapshot, until time.Time, note string) ([]string, []string) {
+
+	tags := PinTags(until, note)
+	existing := pinTagsOf(snapshot)
+
+	add := make([]string, 0)
+	for _, tag := range tags {
+		if !containsString(existing, tag) {
+			add = append(add, tag)
+		}
+	}
+
+	remove := make([]string, 0)
+	for _, tag := range existing {
+		if !containsString(tags, tag) {
+			remove = append(remove, tag)
+		}
+	}
+
+	return add, remove
+}
+
+// ParsePin returns the pin recorded by the tags of a snapshot, and whether the snapshot is pinned at all.
+func ParsePin(snapshot Snapshot) (Pin, bool) {
+
+	pin := Pin{Snapshot: snapshot}
+	pinned := false
+
+	for _, tag := range snapshot.Tags {
+		switch {
+
+		case tag == PinTag:
+			pinned = true
+
+		case strings.HasPrefix(tag, pinUntilPrefix):
+			until, err := time.ParseInLocation(PinDateFormat, strings.TrimPrefix(tag, pinUntilPrefix), time.Local)
+			if err != nil {
+				glog.Warningf("Snapshot %s has an invalid pin expiry tag %q; it is pinned indefinitely", snapshot.ShortID, tag)
+				continue
+			}
+			pin.Until = until
+
+		case strings.HasPrefix(tag, pinNotePrefix):
+			pin.Note = strings.TrimPrefix(tag, pinNotePrefix)
+		}
+	}
+
+	return pin, pinned
+}
+
+// PinsOf returns the pins among a set of snapshots, oldest first.
+func PinsOf(snapshots []Snapshot) []Pin {
+
+	pins := make([]Pin, 0)
+	for _, snapshot := range snapshots {
+		if pin, pinned := ParsePin(snapshot); pinned {
+			pins = append(pins, pin)
+		}
+	}
+
+	sort.SliceStable(pins, func(i, j int) bool {
+		return pins[i].Snapshot.Time.Before(pins[j].Snapshot.Time)
+	})
+
+	return pins
+}
+
+// applyPins keeps those snapshots of a plan that are pinned, as of now. Expired
+// pins are not honoured (i.e., their snapshots are subject to the retention
+// policies alone) and are returned, so that they may be removed once the plan
+// has been applied.
+func applyPins(plan *RetentionPlan, now time.Time) []Pin {
+
+	expired := make([]Pin, 0)
+
+	for i, decision := range plan.Decisions {
+		pin, pinned := ParsePin(decision.Snapshot)
+		if !pinned {
+			continue
+		}
+
+		if pin.Expired(now) {
+			expired = append(expired, pin)
+			continue
+		}
+
+		reason := PinTag
+		if !pin.Until.IsZero() {
+			reason += " until " + pin.Expiry()
+		}
+		plan.Decisions[i].Keep = true
+		plan.Decisions[i].Reasons = append(plan.Decisions[i].Reasons, reason)
+	}
+
+	return expired
+}
+
+// checkPins returns an error if the plan would remove a snapshot with an
+// unexpired pin, as of now. Such snapshots are kept by applyPins; this guards
+// against, e.g., a plan that has not been subject to it.
+func checkPins(plan *RetentionPlan, now time.Time) error {
+
+	for _, snapshot := range plan.Removed() {
+		if pin, pinned := ParsePin(snapshot); pinned && !pin.Expired(now) {
+			return fmt.Errorf("pinned snapshot %s (%s) would be removed",
+				snapshot.ShortID, snapshot.Time.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	return nil
+}
+
+// SavePins records the pins of a profile, as most-recently retrieved from its repository.
+func (appConfig *AppConfiguration) SavePins(profileName string, pins []Pin) error {
+
+	return appConfig.saveState("pins", profileName, pins)
+}
+
+// LoadPins retrieves the recorded pins of a profile (none if they have never been recorded).
+func (appConfig *AppConfiguration) LoadPins(profileName string) ([]Pin, error) {
+
+	pins := make([]Pin, 0)
+
+	if err := appConfig.loadState("pins", profileName, &pins); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return pins, nil
+}
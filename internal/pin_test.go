@@ -0,0 +1,100 @@
+package resticmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestPins(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	until := time.Date(2030, 6, 30, 0, 0, 0, 0, time.Local)
+	g.Expect(PinTags(until, "legal hold, case 42")).Should(gomega.Equal([]string{
+		"pinned", "pinned-until=2030-06-30", "pinned-note=legal hold; case 42",
+	}))
+	g.Expect(PinTags(time.Time{}, " ")).Should(gomega.Equal([]string{"pinned"}))
+
+	now := time.Now()
+	snapshots := []Snapshot{
+		{ShortID: "c", Time: now, Tags: append([]string{"auto"}, PinTags(time.Time{}, "")...)},
+		{ShortID: "a", Time: now.AddDate(0, 0, -2), Tags: []string{"auto"}},
+		{ShortID: "b", Time: now.AddDate(0, 0, -1), Tags: PinTags(until, "migration")},
+	}
+
+	pins := PinsOf(snapshots)
+	g.Expect(pins).Should(gomega.HaveLen(2))
+	g.Expect(pins[0].Snapshot.ShortID).Should(gomega.Equal("b"))
+	g.Expect(pins[0].Expiry()).Should(gomega.Equal("2030-06-30"))
+	g.Expect(pins[0].Note).Should(gomega.Equal("migration"))
+	g.Expect(pins[1].Expiry()).Should(gomega.Equal("never"))
+	g.Expect(pinTagsOf(snapshots[0])).Should(gomega.Equal([]string{"pinned"}))
+
+	// A pin expires at the end of its expiry date
+	g.Expect(pins[0].Expired(until.Add(23 * time.Hour))).Should(gomega.BeFalse())
+	g.Expect(pins[0].Expired(until.AddDate(0, 0, 1))).Should(gomega.BeTrue())
+	g.Expect(pins[1].Expired(until.AddDate(100, 0, 0))).Should(gomega.BeFalse())
+
+	// Removing a pinned snapshot is refused
+	plan := &RetentionPlan{Decisions: []RetentionDecision{
+		{Snapshot: snapshots[1], Keep: false},
+		{Snapshot: snapshots[2], Keep: true},
+	}}
+	g.Expect(checkPins(plan, now)).Should(gomega.Succeed())
+	plan.Decisions[1].Keep = false
+	g.Expect(checkPins(plan, now)).Should(gomega.MatchError(gomega.ContainSubstring("pinned snapshot b")))
+
+	// ... unless the pin has expired
+	g.Expect(checkPins(plan, until.AddDate(0, 0, 1))).Should(gomega.Succeed())
+}
+
+func TestPinTagChanges(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	until := time.Date(2030, 6, 30, 0, 0, 0, 0, time.Local)
+	snapshot := Snapshot{ShortID: "a", Tags: append([]string{"auto"}, PinTags(until, "migration")...)}
+
+	// Re-pinning retains the pin tag (restic removes after adding), replacing only the note
+	add, remove := pinTagChanges(snapshot, until, "legal hold")
+	g.Expect(add).Should(gomega.Equal([]string{"pinned-note=legal hold"}))
+	g.Expect(remove).Should(gomega.Equal([]string{"pinned-note=migration"}))
+
+	// ... or the expiry date
+	add, remove = pinTagChanges(snapshot, time.Time{}, "migration")
+	g.Expect(add).Should(gomega.BeEmpty())
+	g.Expect(remove).Should(gomega.Equal([]string{"pinned-until=2030-06-30"}))
+
+	// Pinning an unpinned snapshot removes nothing
+	add, remove = pinTagChanges(Snapshot{Tags: []string{"auto"}}, until, "")
+	g.Expect(add).Should(gomega.Equal([]string{"pinned", "pinned-until=2030-06-30"}))
+	g.Expect(remove).Should(gomega.BeEmpty())
+}
+
+func TestApplyPins(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	until := time.Date(2030, 6, 30, 0, 0, 0, 0, time.Local)
+	plan := &RetentionPlan{Decisions: []RetentionDecision{
+		{Snapshot: Snapshot{ShortID: "a", Tags: PinTags(time.Time{}, "")}, Keep: false},
+		{Snapshot: Snapshot{ShortID: "b", Tags: PinTags(until, "")}, Keep: false},
+		{Snapshot: Snapshot{ShortID: "c", Tags: []string{"auto"}}, Keep: false},
+	}}
+
+	// Before expiry, pinned snapshots are kept
+	preview := &RetentionPlan{Decisions: append([]RetentionDecision{}, plan.Decisions...)}
+	g.Expect(applyPins(preview, until)).Should(gomega.BeEmpty())
+	g.Expect(preview.Removed()).Should(gomega.HaveLen(1))
+	g.Expect(preview.Decisions[1].Reasons).Should(gomega.Equal([]string{"pinned until 2030-06-30"}))
+
+	// After expiry, the pin is not honoured
+	expired := applyPins(plan, until.AddDate(0, 0, 1))
+	g.Expect(expired).Should(gomega.HaveLen(1))
+	g.Expect(expired[0].Snapshot.ShortID).Should(gomega.Equal("b"))
+	g.Expect(plan.Decisions[0].Keep).Should(gomega.BeTrue())
+	g.Expect(plan.Removed()).Should(gomega.HaveLen(2))
+	g.Expect(checkPins(plan, until.AddDate(0, 0, 1))).Should(gomega.Succeed())
+}
@@ -0,0 +1,186 @@
+package resticmanager
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PreflightGates encapsulates the requirements on a profile source that must be
+// met before it is backed up, guarding against, e.g., backing up the (empty)
+// mount point directory of an unmounted share.
+type PreflightGates struct {
+	// MountPoint requires that the source is itself a mount point.
+	MountPoint bool `mapstructure:"mount-point"`
+	// FilesystemUUID and FilesystemLabel require that the source is on the filesystem with this UUID or label.
+	FilesystemUUID  string `mapstructure:"filesystem-uuid"`
+	FilesystemLabel string `mapstructure:"filesystem-label"`
+	// Sentinel requires that this file exists (relative to the source, unless absolute).
+	Sentinel string `mapstructure:"sentinel"`
+	// MinEntries requires that the source directory has at least this many entries.
+	MinEntries int `mapstructure:"min-entries"`
+}
+
+// mountEntry encapsulates a single mount, as listed in /proc/self/mountinfo.
+type mountEntry struct {
+	MountPoint string
+	FSType     string
+	Device     string
+}
+
+// unescapeMountField decodes the octal escapes (e.g., "\040" for a space) of a mountinfo field.
+func unescapeMountField(field string) string {
+
+	var builder strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(field[i])
+	}
+
+	return builder.String()
+}
+
+// parseMountInfo parses the content of /proc/self/mountinfo, e.g.,
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// (where /mnt2 is the mount point, ext3 the filesystem type and /dev/root the device).
+func parseMountInfo(content string) []mountEntry {
+
+	mounts := make([]mountEntry, 0)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if len(fields) < 5 || separator < 0 || separator+2 >= len(fields) {
+			continue
+		}
+
+		mounts = append(mounts, mountEntry{
+			MountPoint: unescapeMountField(fields[4]),
+			FSType:     fields[separator+1],
+			Device:     unescapeMountField(fields[separator+2]),
+		})
+	}
+
+	return mounts
+}
+
+// findMount returns the mount containing an (absolute, symlink-free) path, i.e.,
+// that with the longest mount point prefixing the path (the last, if several
+// are mounted at the same point), or nil if there is none.
+func findMount(mounts []mountEntry, path string) *mountEntry {
+
+	var found *mountEntry
+	for i, mount := range mounts {
+		point := mount.MountPoint
+		if path != point && point != "/" && !strings.HasPrefix(path, point+"/") {
+			continue
+		}
+		if found == nil || len(point) >= len(found.MountPoint) {
+			found = &mounts[i]
+		}
+	}
+
+	return found
+}
+
+// Check returns the failed gates (none if the source may be backed up).
+func (gates PreflightGates) Check(source string) []error {
+
+	failures := make([]error, 0)
+
+	stat, err := os.Stat(source)
+	if err != nil || !stat.IsDir() {
+		return append(failures, fmt.Errorf("source %s is not a directory", source))
+	}
+
+	if gates.MountPoint || gates.FilesystemUUID != "" || gates.FilesystemLabel != "" {
+		failures = append(failures, gates.checkMount(source)...)
+	}
+
+	if gates.Sentinel != "" {
+		sentinel := gates.Sentinel
+		if !filepath.IsAbs(sentinel) {
+			sentinel = filepath.Join(source, sentinel)
+		}
+		if _, err := os.Stat(sentinel); err != nil {
+			failures = append(failures, fmt.Errorf("sentinel file %s does not exist", sentinel))
+		}
+	}
+
+	if gates.MinEntries > 0 {
+		entries, err := ioutil.ReadDir(source)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("could not read source %s: %v", source, err))
+		} else if len(entries) < gates.MinEntries {
+			failures = append(failures, fmt.Errorf("source %s has %d entries, fewer than the required %d", source, len(entries), gates.MinEntries))
+		}
+	}
+
+	return failures
+}
+
+// checkMount returns the failed mount point and filesystem gates.
+func (gates PreflightGates) checkMount(source string) []error {
+
+	failures := make([]error, 0)
+
+	path, err := filepath.Abs(source)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return append(failures, fmt.Errorf("could not resolve source %s: %v", source, err))
+	}
+
+	mounts, err := readMounts()
+	if err != nil {
+		return append(failures, err)
+	}
+
+	mount := findMount(mounts, path)
+	if mount == nil {
+		return append(failures, fmt.Errorf("could not determine the filesystem of source %s", source))
+	}
+
+	if gates.MountPoint && mount.MountPoint != path {
+		failures = append(failures, fmt.Errorf("source %s is not a mount point (it is on %s)", source, mount.MountPoint))
+	}
+
+	for _, filesystem := range [][2]string{{"uuid", gates.FilesystemUUID}, {"label", gates.FilesystemLabel}} {
+		kind, name := filesystem[0], filesystem[1]
+		if name == "" {
+			continue
+		}
+		device, err := filesystemDevice(kind, name)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("no filesystem with %s %s: %v", kind, name, err))
+			continue
+		}
+		if mounted, err := filepath.EvalSymlinks(mount.Device); err != nil || mounted != device {
+			failures = append(failures, fmt.Errorf("source %s is on %s (%s), not on the filesystem with %s %s (%s)",
+				source, mount.MountPoint, mount.Device, kind, name, device))
+		}
+	}
+
+	return failures
+}
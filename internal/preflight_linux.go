@@ -0,0 +1,28 @@
+//go:build linux
+// +build linux
+
+package resticmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// readMounts returns the current mounts.
+func readMounts() ([]mountEntry, error) {
+
+	content, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("could not read mounts: %v", err)
+	}
+
+	return parseMountInfo(string(content)), nil
+}
+
+// filesystemDevice returns the device holding the filesystem with the
+// specified UUID or label (kind "uuid" or "label"), via /dev/disk.
+func filesystemDevice(kind string, name string) (string, error) {
+
+	return filepath.EvalSymlinks(filepath.Join("/dev/disk", "by-"+kind, name))
+}
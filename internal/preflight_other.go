@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package resticmanager
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readMounts returns the current mounts (not supported on this platform).
+func readMounts() ([]mountEntry, error) {
+
+	return nil, fmt.Errorf("mount checks are not supported on %s", runtime.GOOS)
+}
+
+// filesystemDevice returns the device holding the filesystem with the
+// specified UUID or label (not supported on this platform).
+func filesystemDevice(kind string, name string) (string, error) {
+
+	return "", fmt.Errorf("filesystem checks are not supported on %s", runtime.GOOS)
+}
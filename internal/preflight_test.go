@@ -0,0 +1,62 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestMountInfo(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	mounts := parseMountInfo(`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+35 22 8:17 / /mnt/nas rw,relatime shared:2 - nfs4 nas:/export rw
+36 22 8:33 / /mnt/usb\040disk rw,relatime shared:3 - ext4 /dev/sdc1 rw
+malformed line
+`)
+	g.Expect(mounts).Should(gomega.Equal([]mountEntry{
+		{MountPoint: "/", FSType: "ext4", Device: "/dev/sda1"},
+		{MountPoint: "/mnt/nas", FSType: "nfs4", Device: "nas:/export"},
+		{MountPoint: "/mnt/usb disk", FSType: "ext4", Device: "/dev/sdc1"},
+	}))
+
+	g.Expect(findMount(mounts, "/mnt/nas").MountPoint).Should(gomega.Equal("/mnt/nas"))
+	g.Expect(findMount(mounts, "/mnt/nas/photos").MountPoint).Should(gomega.Equal("/mnt/nas"))
+	g.Expect(findMount(mounts, "/mnt/nasty").MountPoint).Should(gomega.Equal("/"))
+	g.Expect(findMount(mounts, "/mnt/usb disk/a").Device).Should(gomega.Equal("/dev/sdc1"))
+	g.Expect(findMount(mounts[1:], "/home")).Should(gomega.BeNil())
+}
+
+func TestPreflightGates(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "preflight")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	g.Expect(PreflightGates{}.Check(directory)).Should(gomega.BeEmpty())
+	g.Expect(PreflightGates{}.Check(filepath.Join(directory, "missing"))).Should(gomega.HaveLen(1))
+
+	// An empty (e.g., unmounted) source fails the sentinel and minimum content gates
+	gates := PreflightGates{Sentinel: ".mounted", MinEntries: 2}
+	failures := gates.Check(directory)
+	g.Expect(failures).Should(gomega.HaveLen(2))
+	g.Expect(failures[0]).Should(gomega.MatchError(gomega.ContainSubstring("sentinel file")))
+	g.Expect(failures[1]).Should(gomega.MatchError(gomega.ContainSubstring("has 0 entries, fewer than the required 2")))
+
+	g.Expect(ioutil.WriteFile(filepath.Join(directory, ".mounted"), nil, 0600)).Should(gomega.Succeed())
+	g.Expect(os.Mkdir(filepath.Join(directory, "data"), 0700)).Should(gomega.Succeed())
+	g.Expect(gates.Check(directory)).Should(gomega.BeEmpty())
+
+	profile := newTestProfile(map[string]interface{}{
+		"source":    directory,
+		"preflight": map[string]interface{}{"sentinel": ".mounted", "min-entries": 3},
+	})
+	g.Expect(profile.PreflightGates()).Should(gomega.Equal(PreflightGates{Sentinel: ".mounted", MinEntries: 3}))
+	g.Expect(profile.Preflight()).Should(gomega.MatchError(gomega.ContainSubstring("Pre-flight check failed: source")))
+}
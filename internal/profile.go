@@ -1,38 +1,28 @@
 package resticmanager
 
 import (
-	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/i-am-david-fernandez/glog"
 
-	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 	yaml "gopkg.in/yaml.v2"
 )
 
-// ProfileFilter encapsulates profile selection criteria.
-type ProfileFilter struct {
-	OnlyActive bool
-	Names      []string
-	Tags       []string
-}
-
-// NewProfileFilter creates and returns a new, empty ProfileFilter.
-func NewProfileFilter() *ProfileFilter {
-
-	return &ProfileFilter{
-		OnlyActive: true,
-	}
-}
-
 // ProfileConfiguration encapsulates the configuration of a restic backup profile.
 type ProfileConfiguration struct {
-	viper *viper.Viper
+	viper    *viper.Viper
+	defaults map[string]interface{}
+	raw      map[string]interface{}
+	origins  map[string]string
+	issues   []ValidationIssue
+	matrix   map[string]interface{}
 }
 
 // IsActive returns the profile active state.
@@ -71,6 +61,31 @@ func (profile *ProfileConfiguration) Tags() []string {
 	return nil
 }
 
+// Hosts returns the (glob) patterns of the hosts for which the profile is intended.
+// If empty, the profile is intended for all hosts.
+func (profile *ProfileConfiguration) Hosts() []string {
+
+	key := "hosts"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetStringSlice(key)
+	}
+
+	return nil
+}
+
+// ExcludeHosts returns the (glob) patterns of the hosts for which the profile is not intended.
+func (profile *ProfileConfiguration) ExcludeHosts() []string {
+
+	key := "exclude-hosts"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetStringSlice(key)
+	}
+
+	return nil
+}
+
 // File returns the profile file.
 func (profile *ProfileConfiguration) File() string {
 
@@ -143,14 +158,7 @@ func (profile *ProfileConfiguration) LogFile() string {
 	key := "logging.file"
 
 	if profile.viper.IsSet(key) {
-		filename := profile.viper.GetString(key)
-
-		// TODO: Deprecate/remove this method of substitution.
-		// Expand/substitute source or repo directory if required
-		filename = strings.Replace(filename, "<source>", profile.Source(), -1)
-		filename = strings.Replace(filename, "<repo>", profile.Repository(), -1)
-
-		return filename
+		return profile.viper.GetString(key)
 	}
 
 	return ""
@@ -235,10 +243,112 @@ func (profile *ProfileConfiguration) EmailThresholds() map[glog.LogLevel]int {
 	return thresholds
 }
 
-// RetentionPolicy encapsulates a repository retention policy
+// emailTemplateLayer returns a configured template, read from file (resolved
+// relative to the profile file) or given inline.
+func (profile *ProfileConfiguration) emailTemplateLayer(fileKey string, inlineKey string) string {
+
+	if profile.viper.IsSet(fileKey) {
+		content, err := readTemplateFile(profile.viper.GetString(fileKey), profile.File())
+		if err != nil {
+			glog.Errorf("%v", err)
+		} else {
+			return content
+		}
+	}
+
+	if profile.viper.IsSet(inlineKey) {
+		return profile.viper.GetString(inlineKey)
+	}
+
+	return ""
+}
+
+// EmailTemplate returns the (optional) profile-level html email template layer.
+func (profile *ProfileConfiguration) EmailTemplate() string {
+
+	return profile.emailTemplateLayer("email.template-file", "email.template")
+}
+
+// EmailTextTemplate returns the (optional) profile-level plain-text email template layer.
+func (profile *ProfileConfiguration) EmailTextTemplate() string {
+
+	return profile.emailTemplateLayer("email.text-template-file", "email.text-template")
+}
+
+// EmailAttachLog returns true if the profile log file should be attached to emails.
+func (profile *ProfileConfiguration) EmailAttachLog() bool {
+
+	key := "email.attach-log"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetBool(key)
+	}
+
+	return false
+}
+
+// EmailAttachOutput returns true if the raw restic output should be attached to emails.
+func (profile *ProfileConfiguration) EmailAttachOutput() bool {
+
+	key := "email.attach-output"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetBool(key)
+	}
+
+	return false
+}
+
+// EmailCompressAbove returns the size (in bytes) above which email attachments are compressed.
+func (profile *ProfileConfiguration) EmailCompressAbove() int64 {
+
+	key := "email.compress-above"
+
+	if profile.viper.IsSet(key) {
+		size, err := sizeInBytes(profile.viper.GetString(key))
+		if err == nil {
+			return size
+		}
+		glog.Warningf("Invalid %s (%v); using the default.", key, err)
+	}
+
+	return 1024 * 1024
+}
+
+// RetentionPolicy encapsulates a single repository retention rule, applied via
+// "restic forget --keep-<period> <value>", e.g., {daily, 7}, {within-weekly, 6m}
+// or {tag, important}.
 type RetentionPolicy struct {
 	Period string
-	Value  int
+	Value  string
+}
+
+// Validate returns an error if the policy period is unknown, or if its value is invalid for the period.
+func (policy RetentionPolicy) Validate() error {
+
+	switch {
+
+	case containsString(RetentionCountPeriods, policy.Period):
+		// -1 keeps an unlimited number of snapshots
+		if count, err := strconv.Atoi(policy.Value); err != nil || count == 0 || count < -1 {
+			return fmt.Errorf("invalid %s value %q (expected a positive number of snapshots, or -1 for unlimited)", policy.Period, policy.Value)
+		}
+
+	case containsString(RetentionWithinPeriods, policy.Period):
+		if !resticDurationRegexp.MatchString(policy.Value) {
+			return fmt.Errorf("invalid %s value %q (expected a duration, e.g., 2y5m7d3h)", policy.Period, policy.Value)
+		}
+
+	case policy.Period == RetentionTagPeriod:
+		if policy.Value == "" {
+			return fmt.Errorf("empty %s value", policy.Period)
+		}
+
+	default:
+		return fmt.Errorf("invalid period %q (expected one of %s)", policy.Period, strings.Join(RetentionPeriods, ", "))
+	}
+
+	return nil
 }
 
 // RetentionPolicies returns the profile retention policies.
@@ -261,6 +371,340 @@ func (profile *ProfileConfiguration) RetentionPolicies() []RetentionPolicy {
 	return nil
 }
 
+// Hostname returns the hostname recorded in the profile snapshots.
+func (profile *ProfileConfiguration) Hostname() string {
+
+	key := "hostname"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetString(key)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		glog.Warningf("Could not determine hostname: %v", err)
+	}
+
+	return hostname
+}
+
+// snapshotPath returns the path of the profile source, as recorded by restic (i.e., absolute).
+func (profile *ProfileConfiguration) snapshotPath() string {
+
+	source := profile.Source()
+	if absolute, err := filepath.Abs(source); err == nil && source != "" {
+		source = absolute
+	}
+
+	return source
+}
+
+// SnapshotTags returns the tags applied to the profile snapshots.
+func (profile *ProfileConfiguration) SnapshotTags() []string {
+
+	key := "snapshot-tags"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetStringSlice(key)
+	}
+
+	return nil
+}
+
+// RetentionGroupBy returns the snapshot grouping used when applying retention policies
+// (if empty, restic's default is used).
+func (profile *ProfileConfiguration) RetentionGroupBy() string {
+
+	key := "retention.group-by"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetString(key)
+	}
+
+	return ""
+}
+
+// RetentionScope returns the snapshot properties (see RetentionScopes) to which
+// retention policy application is restricted.
+func (profile *ProfileConfiguration) RetentionScope() []string {
+
+	key := "retention.scope"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetStringSlice(key)
+	}
+
+	return RetentionScopes
+}
+
+// RetentionGuard returns the limits on the snapshots that retention policy application may remove.
+func (profile *ProfileConfiguration) RetentionGuard() RetentionGuard {
+
+	guard := RetentionGuard{
+		MaxRemoveFraction: 0.5,
+		ProtectNewerThan:  24 * time.Hour,
+	}
+
+	if key := "retention.max-remove-fraction"; profile.viper.IsSet(key) {
+		guard.MaxRemoveFraction = profile.viper.GetFloat64(key)
+	}
+
+	if key := "retention.protect-newer-than"; profile.viper.IsSet(key) {
+		guard.ProtectNewerThan = profile.viper.GetDuration(key)
+	}
+
+	return guard
+}
+
+// CheckRetentionPlan returns an error if a retention plan may not be applied to
+// the profile repository, i.e., if it violates the profile retention guard or
+// would remove a pinned snapshot, as of now.
+func (profile *ProfileConfiguration) CheckRetentionPlan(plan *RetentionPlan, now time.Time) error {
+
+	if err := profile.RetentionGuard().Check(plan, now); err != nil {
+		return err
+	}
+
+	return checkPins(plan, now)
+}
+
+// ForgetArguments returns the restic forget arguments that apply the profile
+// retention policies, scoped to the profile snapshots (see RetentionScope).
+// Pinned snapshots (see PinTag) are not considered here, but kept by RetentionPlan.
+func (profile *ProfileConfiguration) ForgetArguments() []string {
+
+	arguments := make([]string, 0)
+
+	policies := profile.RetentionPolicies()
+	for _, policy := range policies {
+		arguments = append(arguments, fmt.Sprintf("--keep-%s", policy.Period), policy.Value)
+	}
+
+	if groupBy := profile.RetentionGroupBy(); groupBy != "" {
+		arguments = append(arguments, "--group-by", groupBy)
+	}
+
+	for _, scope := range profile.RetentionScope() {
+		switch scope {
+
+		case "host":
+			if hostname := profile.Hostname(); hostname != "" {
+				arguments = append(arguments, "--host", hostname)
+			}
+
+		case "paths":
+			// restic records absolute paths
+			if source := profile.Source(); source != "" {
+				if absolute, err := filepath.Abs(source); err == nil {
+					source = absolute
+				}
+				arguments = append(arguments, "--path", source)
+			}
+
+		case "tags":
+			// Snapshots must have all of the tags
+			if tags := profile.SnapshotTags(); len(tags) > 0 {
+				arguments = append(arguments, "--tag", strings.Join(tags, ","))
+			}
+		}
+	}
+
+	return append(arguments, profile.Arguments("forget")...)
+}
+
+// CheckMode returns the check mode (see CheckModes).
+func (profile *ProfileConfiguration) CheckMode() string {
+
+	key := "check.mode"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetString(key)
+	}
+
+	return CheckMetadata
+}
+
+// CheckSubsets returns the number of parts into which pack data is divided for
+// reading, one (or, within the time budget, more) per check.
+func (profile *ProfileConfiguration) CheckSubsets() int {
+
+	key := "check.subsets"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetInt(key)
+	}
+
+	return 10
+}
+
+// CheckBudget returns the time budget for reading pack data (in budget mode).
+func (profile *ProfileConfiguration) CheckBudget() time.Duration {
+
+	key := "check.budget"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetDuration(key)
+	}
+
+	return time.Hour
+}
+
+// VerifyRestoreSample returns the number of randomly-sampled files restored by the verify-restore operation.
+func (profile *ProfileConfiguration) VerifyRestoreSample() int {
+
+	key := "verify-restore.sample"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetInt(key)
+	}
+
+	return 10
+}
+
+// VerifyRestorePaths returns the (absolute) critical paths always restored by
+// the verify-restore operation. Relative paths are relative to the profile source.
+func (profile *ProfileConfiguration) VerifyRestorePaths() []string {
+
+	key := "verify-restore.paths"
+
+	paths := make([]string, 0)
+	for _, path := range profile.viper.GetStringSlice(key) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(profile.Source(), path)
+		}
+		if absolute, err := filepath.Abs(path); err == nil {
+			path = absolute
+		}
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// ContentAssertions returns the requirements on the content of each snapshot,
+// with (absolute) paths. Relative paths are relative to the profile source.
+func (profile *ProfileConfiguration) ContentAssertions() []ContentAssertion {
+
+	key := "must-contain"
+
+	if profile.viper.IsSet(key) {
+
+		var assertions []ContentAssertion
+
+		if err := profile.viper.UnmarshalKey(key, &assertions); err != nil {
+			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
+			return nil
+		}
+
+		for i, assertion := range assertions {
+			if assertion.Path != "" && !filepath.IsAbs(assertion.Path) {
+				source := profile.Source()
+				if absolute, err := filepath.Abs(source); err == nil {
+					source = absolute
+				}
+				assertions[i].Path = filepath.Join(source, assertion.Path)
+			}
+		}
+
+		return assertions
+	}
+
+	return nil
+}
+
+// PreflightGates returns the requirements on the source that must be met before it is backed up.
+func (profile *ProfileConfiguration) PreflightGates() PreflightGates {
+
+	key := "preflight"
+
+	var gates PreflightGates
+
+	if profile.viper.IsSet(key) {
+		if err := profile.viper.UnmarshalKey(key, &gates); err != nil {
+			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
+		}
+	}
+
+	return gates
+}
+
+// Preflight checks the profile source against its pre-flight gates (see
+// PreflightGates), returning an error describing the failed gates, if any.
+func (profile *ProfileConfiguration) Preflight() error {
+
+	failures := profile.PreflightGates().Check(profile.Source())
+	if len(failures) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		messages = append(messages, failure.Error())
+	}
+
+	return fmt.Errorf("Pre-flight check failed: %s", strings.Join(messages, "; "))
+}
+
+// RepositoryIDs returns the configured (restic) repository IDs accepted at the
+// profile repository location, e.g., the members of a set of rotating disks.
+func (profile *ProfileConfiguration) RepositoryIDs() []string {
+
+	key := "repository-identity.ids"
+
+	return profile.viper.GetStringSlice(key)
+}
+
+// RotationOverdueAfter returns the time since its last backup after which a repository (rotation member) is overdue.
+func (profile *ProfileConfiguration) RotationOverdueAfter() time.Duration {
+
+	key := "repository-identity.overdue-after"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetDuration(key)
+	}
+
+	return 7 * 24 * time.Hour
+}
+
+// PruneRule returns the rule deciding when the prune operation prunes the repository.
+func (profile *ProfileConfiguration) PruneRule() PruneRule {
+
+	rule := PruneRule{
+		Mode:            PruneNeeded,
+		UnusedThreshold: "10%",
+	}
+
+	if key := "prune.when"; profile.viper.IsSet(key) {
+		rule.Mode = profile.viper.GetString(key)
+	}
+
+	if key := "prune.unused-threshold"; profile.viper.IsSet(key) {
+		rule.UnusedThreshold = profile.viper.GetString(key)
+	}
+
+	return rule
+}
+
+// PruneArguments returns the restic prune arguments that apply the profile prune options.
+func (profile *ProfileConfiguration) PruneArguments() []string {
+
+	arguments := make([]string, 0)
+
+	if key := "prune.max-unused"; profile.viper.IsSet(key) {
+		arguments = append(arguments, "--max-unused", profile.viper.GetString(key))
+	}
+
+	if key := "prune.max-repack-size"; profile.viper.IsSet(key) {
+		arguments = append(arguments, "--max-repack-size", profile.viper.GetString(key))
+	}
+
+	if key := "prune.repack-cacheable-only"; profile.viper.IsSet(key) && profile.viper.GetBool(key) {
+		arguments = append(arguments, "--repack-cacheable-only")
+	}
+
+	return append(arguments, profile.Arguments("prune")...)
+}
+
 // ChangeThreshold encapsulates a set of snapshot diff change thresholds
 type ChangeThreshold struct {
 	TotalFiles int
@@ -287,6 +731,49 @@ func (profile *ProfileConfiguration) ChangeThresholds() *ChangeThreshold {
 	return nil
 }
 
+// AnomalyOptions returns the snapshot diff anomaly detection settings (see DefaultAnomalyOptions).
+func (profile *ProfileConfiguration) AnomalyOptions() AnomalyOptions {
+
+	key := "anomaly-detection"
+
+	options := DefaultAnomalyOptions()
+
+	if profile.viper.IsSet(key) {
+		if err := profile.viper.UnmarshalKey(key, &options); err != nil {
+			glog.Errorf("Could not retrieve configuration key %s: %v", key, err)
+		}
+	}
+
+	return options
+}
+
+// Schedule returns the (informational) profile schedule, e.g., "daily 02:00".
+// It is not acted upon by restic-manager itself (which is typically run by cron or similar).
+func (profile *ProfileConfiguration) Schedule() string {
+
+	key := "schedule"
+
+	if profile.viper.IsSet(key) {
+		return profile.viper.GetString(key)
+	}
+
+	return ""
+}
+
+// Operations lists the operations that may appear in a profile operation sequence.
+var Operations = []string{
+	"initialise",
+	"unlock",
+	"backup",
+	"check",
+	"apply-retention",
+	"prune",
+	"verify-restore",
+	"show-snapshots",
+	"show-listing",
+	"diff",
+}
+
 // OperationSequence returns the profile operation sequence.
 func (profile *ProfileConfiguration) OperationSequence() []string {
 
@@ -303,127 +790,198 @@ func (profile *ProfileConfiguration) OperationSequence() []string {
 func NewProfileConfiguration() *ProfileConfiguration {
 
 	return &ProfileConfiguration{
-		viper: viper.New(),
+		viper:   viper.New(),
+		origins: make(map[string]string),
 	}
 }
 
 // LoadProfileConfiguration creates and returns a new ProfileConfiguration populated from a file.
-func LoadProfileConfiguration(filename string) *ProfileConfiguration {
+func LoadProfileConfiguration(filename string) (*ProfileConfiguration, error) {
 
 	profile := NewProfileConfiguration()
 
-	profile.Load(filename)
+	err := profile.Load(filename)
 
-	return profile
+	return profile, err
 }
 
-// SetDefaults populates an existing ProfileConfiguration with defaults from a given map.
+// SetDefaults sets the defaults (e.g., the application profile-defaults) upon
+// which a subsequently-loaded profile is (deep-)merged.
 func (profile *ProfileConfiguration) SetDefaults(defaults map[string]interface{}) {
 
-	for k, v := range defaults {
-		profile.viper.SetDefault(k, v)
-	}
+	profile.defaults = defaults
 }
 
-// expandSubstitutions expands fields/values that allow substitutions
-func (profile *ProfileConfiguration) expandSubstitutions() {
-
-	c := profile.viper.AllSettings()
+// Load populates an existing ProfileConfiguration from a file, deep-merging
+// the profile content onto any defaults (see mergeSettings for details).
+// Any included fragments (see mergeProfileFile) are merged, in order, before
+// the profile's own keys. A profile defining a matrix must instead be loaded
+// via LoadProfileConfigurations.
+func (profile *ProfileConfiguration) Load(filename string) error {
 
-	// Substitute exclusions
-	key := "exclusions"
-	vss := profile.viper.GetStringSlice(key)
-	for i, v := range vss {
-		if tmpl, err := template.New("t").Parse(v); err != nil {
-			glog.Errorf("Could not parse template: %v", err)
-		} else {
-			var buffer bytes.Buffer
-			tmpl.Execute(&buffer, c)
-			vss[i] = buffer.String()
-		}
+	settings, err := profile.loadSettings(filename)
+	if err != nil {
+		return err
 	}
-	profile.viper.Set(key, vss)
 
-	// Substitute log file
-	key = "logging.file"
-	vs := profile.viper.GetString(key)
-	if tmpl, err := template.New("t").Parse(vs); err != nil {
-		glog.Errorf("Could not parse template: %v", err)
-	} else {
-		var buffer bytes.Buffer
-		tmpl.Execute(&buffer, c)
-		vs = buffer.String()
+	if _, ok := settings[matrixKey]; ok {
+		return fmt.Errorf("Profile %v defines a matrix", filename)
 	}
-	profile.viper.Set(key, vs)
 
+	return profile.applySettings(settings, nil)
 }
 
-// Load populates an existing ProfileConfiguration from a file.
-func (profile *ProfileConfiguration) Load(filename string) {
+// loadSettings reads (and validates) a profile file and returns its settings,
+// merged onto the defaults but not yet expanded.
+func (profile *ProfileConfiguration) loadSettings(filename string) (map[string]interface{}, error) {
 
 	profile.viper.SetConfigFile(filename)
-	if err := profile.viper.ReadInConfig(); err != nil {
-		glog.Errorf("Could not read profile from %v: %v", profile.viper.ConfigFileUsed(), err)
-	} else {
-		glog.Debugf("Read profile from %v", profile.viper.ConfigFileUsed())
+
+	settings := make(map[string]interface{})
+
+	if err := mergeSettings(settings, profile.defaults, OriginDefaults, profile.origins, ""); err != nil {
+		return nil, fmt.Errorf("Could not merge profile defaults: %v", err)
 	}
 
-	profile.expandSubstitutions()
+	// Validate the profile and each included fragment as it is read
+	validate := func(file string) {
+		issues, err := ValidateSettingsFile(file, ProfileSchema())
+		if err != nil {
+			glog.Debugf("Could not validate %v: %v", file, err)
+			return
+		}
+		for _, issue := range issues {
+			glog.Warningf("Profile configuration: %v", issue)
+		}
+		profile.issues = append(profile.issues, issues...)
+	}
+
+	raw, err := mergeProfileFile(settings, filename, profile.origins, nil, validate)
+	if err != nil {
+		return nil, err
+	}
+
+	glog.Debugf("Read profile from %v", filename)
+	profile.raw = raw
+
+	return settings, nil
 }
 
-// MatchesFilter returns true if the ProfileConfiguration matches the specified filter criteria.
-func (profile *ProfileConfiguration) MatchesFilter(filter ProfileFilter) (bool, string) {
+// applySettings expands (see expandSettings) and applies loaded settings to
+// the profile, with extra (if not nil) as additional template context.
+func (profile *ProfileConfiguration) applySettings(settings map[string]interface{}, extra map[string]interface{}) error {
+
+	// Secrets are registered both before and after expansion, so that both forms are redacted
+	registerSettingsSecrets(settings)
+	defer registerSettingsSecrets(settings)
 
-	if filter.OnlyActive && (!profile.IsActive()) {
-		return false, "not active"
+	if errs := expandSettings(settings, extra, profile.origins); len(errs) > 0 {
+		for _, err := range errs[1:] {
+			glog.Errorf("%v", err)
+		}
+		return errs[0]
 	}
 
-	if len(filter.Names) > 0 {
-		// The filter has some names. Verify that we match one of them
-		profileName := profile.Name()
-		matched := false
-		for _, name := range filter.Names {
-			if name == profileName {
-				matched = true
-				break
-			}
+	profile.viper.MergeConfigMap(settings)
+
+	for _, policy := range profile.RetentionPolicies() {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("Invalid keep-policy (%s): %v", originOf(profile.origins, "keep-policy"), err)
 		}
+	}
 
-		if !matched {
-			return false, "name not matched"
+	for _, assertion := range profile.ContentAssertions() {
+		if err := assertion.Validate(); err != nil {
+			return fmt.Errorf("Invalid must-contain (%s): %v", originOf(profile.origins, "must-contain"), err)
 		}
 	}
 
-	if len(filter.Tags) > 0 {
+	if mode := profile.CheckMode(); !containsString(CheckModes, mode) {
+		return fmt.Errorf("Invalid check mode %q (%s)", mode, originOf(profile.origins, "check"))
+	}
+	if subsets := profile.CheckSubsets(); subsets < 1 {
+		return fmt.Errorf("Invalid check subsets %d (%s); expected a positive number", subsets, originOf(profile.origins, "check"))
+	}
 
-		// The filter has some tags. Verify that all are present in the profile
-		profileTags := profile.Tags()
+	if err := profile.PruneRule().Validate(); err != nil {
+		return fmt.Errorf("Invalid prune options (%s): %v", originOf(profile.origins, "prune"), err)
+	}
 
-		if profileTags != nil {
+	return nil
+}
 
-			// Create a set(-like) object from our slice of tags to aid intersection check
-			profileTagSet := make(map[string]struct{})
-			for _, tag := range profileTags {
-				profileTagSet[tag] = struct{}{}
-			}
+// MatrixValue returns the matrix value from which the profile was generated
+// (or an empty string for a profile not generated from a matrix).
+func (profile *ProfileConfiguration) MatrixValue() string {
 
-			for _, tag := range filter.Tags {
-				if _, exists := profileTagSet[tag]; !exists {
-					// A required (filter) tag is not present in the profile.
-					return false, "tags not matched"
-				}
-			}
+	if profile.matrix == nil {
+		return ""
+	}
 
-		} else {
-			// Profile has no tags. Match fails
-			return false, "no tags to match"
-		}
+	return fmt.Sprintf("%v", profile.matrix["value"])
+}
+
+// Issues returns the schema validation issues found when the profile (and any included fragments) was loaded.
+func (profile *ProfileConfiguration) Issues() []ValidationIssue {
+
+	return profile.issues
+}
+
+// Includes returns the list of fragment files included by the profile, as declared.
+func (profile *ProfileConfiguration) Includes() []string {
+
+	includes, _ := includeList(profile.raw)
+	return includes
+}
+
+// SetActive sets the profile active state by editing the profile file, preserving
+// its layout and comments. Note that all profiles generated from a matrix share
+// a single file.
+func (profile *ProfileConfiguration) SetActive(active bool) error {
+
+	filename := profile.File()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("Editing of %v is not supported (only YAML profiles can be edited)", filename)
+	}
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
 	}
 
-	return true, ""
+	doc, err := parseYAMLDocument(content)
+	if err != nil {
+		return fmt.Errorf("Could not parse %v: %v", filename, err)
+	}
+
+	edited, err := doc.setTopLevelScalar("active", fmt.Sprintf("%v", active))
+	if err != nil {
+		return fmt.Errorf("Could not edit %v: %v", filename, err)
+	}
+
+	if err := ioutil.WriteFile(filename+".tmp", edited, stat.Mode()); err != nil {
+		return fmt.Errorf("Could not write %v: %v", filename, err)
+	}
+
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return err
+	}
+
+	profile.viper.Set("active", active)
+
+	return nil
 }
 
-// SourceIsPresent returns true if the Profile source directory exists.
+// SourceIsPresent returns true if the Profile source directory exists. See
+// also Preflight, which additionally checks the source against the pre-flight gates.
 func (profile *ProfileConfiguration) SourceIsPresent() bool {
 
 	source := profile.Source()
@@ -439,10 +997,35 @@ func (profile *ProfileConfiguration) SourceIsPresent() bool {
 	return false
 }
 
-// String returns a string representation of the ProfileConfiguration
+// Origin returns the origin (i.e., the profile file or the profile-defaults) of a (leaf) configuration value.
+func (profile *ProfileConfiguration) Origin(key string) string {
+
+	return profile.origins[key]
+}
+
+// RawString returns a string representation of the content of the profile file alone (i.e., without defaults),
+// with secret values (e.g., the password) redacted.
+func (profile *ProfileConfiguration) RawString() string {
+
+	content, err := yaml.Marshal(redactSettings(profile.raw))
+	if err != nil {
+		glog.Errorf("Unable to marshal config to YAML: %v", err)
+		return ""
+	}
+	return string(content)
+}
+
+// AnnotatedString returns a flat representation of the effective profile configuration,
+// annotated with the origin of each value, with secret values redacted.
+func (profile *ProfileConfiguration) AnnotatedString() string {
+
+	return annotateSettings(redactSettings(profile.viper.AllSettings()), profile.origins)
+}
+
+// String returns a string representation of the ProfileConfiguration, with secret values redacted.
 func (profile *ProfileConfiguration) String() string {
 
-	c := profile.viper.AllSettings()
+	c := redactSettings(profile.viper.AllSettings())
 
 	content, err := yaml.Marshal(c)
 	if err != nil {
@@ -452,57 +1035,169 @@ func (profile *ProfileConfiguration) String() string {
 	return string(content)
 }
 
-func recursiveFindProfiles(profiles []string, directory string) []string {
+// ProfileIgnoreFile is the name of a file, within a profile directory, listing
+// (glob) patterns of files and directories to be ignored during profile discovery.
+// Patterns apply to the directory containing the ignore file and all of its
+// subdirectories, and are matched against both the item name and its path
+// relative to that directory. Blank lines and lines starting with "#" are ignored.
+const ProfileIgnoreFile = ".restic-manager-ignore"
+
+// ignoreRule is a single profile discovery ignore pattern, relative to a base directory.
+type ignoreRule struct {
+	base    string
+	pattern string
+}
+
+// readIgnoreRules reads the ignore rules (if any) from a directory.
+func readIgnoreRules(directory string) ([]ignoreRule, error) {
+
+	content, err := ioutil.ReadFile(filepath.Join(directory, ProfileIgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rules := make([]ignoreRule, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, ignoreRule{base: directory, pattern: strings.TrimSuffix(line, "/")})
+	}
+
+	return rules, nil
+}
+
+// isIgnored returns true if the specified path matches any ignore rule.
+func isIgnored(fullPath string, rules []ignoreRule) bool {
+
+	name := filepath.Base(fullPath)
+
+	for _, rule := range rules {
+
+		if matched, _ := filepath.Match(rule.pattern, name); matched {
+			return true
+		}
+
+		if relative, err := filepath.Rel(rule.base, fullPath); err == nil {
+			if matched, _ := filepath.Match(rule.pattern, relative); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isHiddenOrBackup returns true if a file name denotes a hidden file or an editor/backup file.
+func isHiddenOrBackup(name string) bool {
+
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+
+	if strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#") {
+		return true
+	}
+
+	switch filepath.Ext(name) {
+	case ".bak", ".orig", ".swp", ".swo", ".tmp":
+		return true
+	}
+
+	return false
+}
+
+// isProfileFile returns true if a file has an extension of a configuration format supported by viper.
+func isProfileFile(name string) bool {
+
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+
+	for _, supported := range viper.SupportedExts {
+		if ext == supported {
+			return true
+		}
+	}
+
+	return false
+}
+
+func recursiveFindProfiles(profiles []string, directory string, rules []ignoreRule, errs []error) ([]string, []error) {
+
+	localRules, err := readIgnoreRules(directory)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Could not read ignore file in %v: %v", directory, err))
+	}
+	rules = append(append([]ignoreRule{}, rules...), localRules...)
 
-	fsRoot := afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), directory))
+	items, err := ioutil.ReadDir(directory)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Could not read profile directory %v: %v", directory, err))
+		return profiles, errs
+	}
 
-	items, _ := afero.ReadDir(fsRoot, "/")
 	for _, item := range items {
 
-		fullItem := path.Join(directory, item.Name())
+		fullItem := filepath.Join(directory, item.Name())
+
+		if isHiddenOrBackup(item.Name()) || isIgnored(fullItem, rules) {
+			glog.Debugf("Ignoring %v", fullItem)
+			continue
+		}
 
 		if item.IsDir() {
-			profiles = recursiveFindProfiles(profiles, fullItem)
-		} else {
-			switch filepath.Ext(item.Name()) {
-			case ".yaml", ".json":
-				profiles = append(profiles, fullItem)
-			}
+			profiles, errs = recursiveFindProfiles(profiles, fullItem, rules, errs)
+		} else if isProfileFile(item.Name()) {
+			profiles = append(profiles, fullItem)
 		}
 	}
 
-	return profiles
+	return profiles, errs
 }
 
-// FindProfiles searches for profile configuration files in a specified location and returns a list of found files.
-func FindProfiles(directory string) []string {
+// FindProfiles searches for profile configuration files in a specified location and returns a list of found files,
+// along with any errors encountered during the search.
+func FindProfiles(directory string) ([]string, []error) {
 
 	profiles := make([]string, 0)
 
 	directory, _ = filepath.Abs(directory)
-	profiles = recursiveFindProfiles(profiles, directory)
-
-	return profiles
+	return recursiveFindProfiles(profiles, directory, nil, make([]error, 0))
 }
 
-// LoadProfiles loads the subset of the specified set of profile files that match the specified filter criteria.
-func LoadProfiles(files []string, filter ProfileFilter, defaults map[string]interface{}) []*ProfileConfiguration {
+// LoadAllProfiles loads the specified set of profile files. A profile file
+// defining a matrix yields one profile per matrix value. Profiles that cannot
+// be loaded (e.g., due to a missing include fragment) are excluded and reported
+// via the returned list of errors. Matrix commands are run only if runCommands
+// is true (see LoadProfileConfigurations).
+func LoadAllProfiles(files []string, defaults map[string]interface{}, runCommands bool) ([]*ProfileConfiguration, []error) {
 
 	profiles := make([]*ProfileConfiguration, 0)
+	errs := make([]error, 0)
 
 	for _, file := range files {
 
-		profile := NewProfileConfiguration()
-		profile.SetDefaults(defaults)
-		profile.Load(file)
-
-		if match, reason := profile.MatchesFilter(filter); !match {
-			glog.Debugf("Skipping profile (filter criteria not matched: %s).", reason)
-			continue
+		loaded, err := LoadProfileConfigurations(file, defaults, runCommands)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Could not load profile %v: %v", file, err))
 		}
 
-		profiles = append(profiles, profile)
+		profiles = append(profiles, loaded...)
 	}
 
-	return profiles
+	return profiles, errs
+}
+
+// LoadProfiles loads the subset of the specified set of profile files that match the specified filter criteria
+// (see LoadAllProfiles and FilterProfiles).
+func LoadProfiles(files []string, filter ProfileFilter, defaults map[string]interface{}) ([]*ProfileConfiguration, []error) {
+
+	profiles, errs := LoadAllProfiles(files, defaults, true)
+
+	selected, _ := FilterProfiles(profiles, filter)
+
+	return selected, errs
 }
@@ -0,0 +1,173 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestRedactSettings(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	settings := map[string]interface{}{
+		"name":     "home",
+		"password": "hunter2",
+		"email": map[string]interface{}{
+			"smtp-password": "secret",
+			"api-token":     "",
+			"level":         "info",
+		},
+	}
+
+	redacted := redactSettings(settings)
+	g.Expect(redacted["name"]).Should(gomega.Equal("home"))
+	g.Expect(redacted["password"]).Should(gomega.Equal(RedactedValue))
+	g.Expect(redacted["email"]).Should(gomega.Equal(map[string]interface{}{
+		"smtp-password": RedactedValue,
+		"api-token":     "",
+		"level":         "info",
+	}))
+
+	// The original settings are not modified
+	g.Expect(settings["password"]).Should(gomega.Equal("hunter2"))
+
+	profile := newTestProfile(settings)
+	g.Expect(profile.String()).ShouldNot(gomega.ContainSubstring("hunter2"))
+	g.Expect(profile.AnnotatedString()).ShouldNot(gomega.ContainSubstring("hunter2"))
+}
+
+func TestSetActive(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "active")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	content := `# Home directories
+name: home
+source: /home
+repo: /backup/home
+active: no   # pending password
+tags: [nightly]
+`
+	filename := filepath.Join(directory, "home.yml")
+	g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+
+	profile, err := LoadProfileConfiguration(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profile.IsActive()).Should(gomega.BeFalse())
+
+	g.Expect(profile.SetActive(true)).Should(gomega.Succeed())
+	g.Expect(profile.IsActive()).Should(gomega.BeTrue())
+
+	edited, err := ioutil.ReadFile(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(edited)).Should(gomega.Equal(`# Home directories
+name: home
+source: /home
+repo: /backup/home
+active: true   # pending password
+tags: [nightly]
+`))
+
+	// A missing key is appended
+	g.Expect(ioutil.WriteFile(filename, []byte("name: home"), 0600)).Should(gomega.Succeed())
+	g.Expect(profile.SetActive(false)).Should(gomega.Succeed())
+	edited, err = ioutil.ReadFile(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(edited)).Should(gomega.Equal("name: home\nactive: false\n"))
+
+	// Flow mappings are not edited
+	g.Expect(ioutil.WriteFile(filename, []byte("{name: home}\n"), 0600)).Should(gomega.Succeed())
+	g.Expect(profile.SetActive(true)).ShouldNot(gomega.Succeed())
+}
+
+func TestProfileScaffold(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	defaults := map[string]interface{}{
+		"password":   "default-secret",
+		"exclusions": []interface{}{"*.tmp"},
+	}
+
+	content := ProfileScaffold("home", "/home", "", []string{"nightly", "laptop"}, defaults)
+	g.Expect(string(content)).ShouldNot(gomega.ContainSubstring("default-secret"))
+	g.Expect(string(content)).Should(gomega.ContainSubstring("# exclusions:\n# - '*.tmp'\n"))
+
+	directory, err := ioutil.TempDir("", "scaffold")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	filename := filepath.Join(directory, "home.yml")
+	g.Expect(ioutil.WriteFile(filename, content, 0600)).Should(gomega.Succeed())
+
+	profile, err := LoadProfileConfiguration(filename)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(profile.Name()).Should(gomega.Equal("home"))
+	g.Expect(profile.Source()).Should(gomega.Equal("/home"))
+	g.Expect(profile.Repository()).Should(gomega.Equal(""))
+	g.Expect(profile.IsActive()).Should(gomega.BeFalse())
+	g.Expect(profile.Tags()).Should(gomega.Equal([]string{"nightly", "laptop"}))
+}
+
+func TestRetentionPolicies(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	for policy, valid := range map[RetentionPolicy]bool{
+		{"daily", "7"}:             true,
+		{"last", "-1"}:             true,
+		{"last", "0"}:              false,
+		{"weekly", "many"}:         false,
+		{"within", "2y5m7d3h"}:     true,
+		{"within-daily", "7d"}:     true,
+		{"within-weekly", "6 wks"}: false,
+		{"tag", "important"}:       true,
+		{"tag", ""}:                false,
+		{"fortnightly", "2"}:       false,
+	} {
+		g.Expect(policy.Validate() == nil).Should(gomega.Equal(valid), "%+v", policy)
+	}
+
+	profile := newTestProfile(map[string]interface{}{
+		"source":        "/home",
+		"hostname":      "nas",
+		"snapshot-tags": []interface{}{"auto", "home"},
+		"keep-policy": []interface{}{
+			map[string]interface{}{"period": "daily", "value": 7},
+			map[string]interface{}{"period": "within", "value": "1y"},
+			map[string]interface{}{"period": "tag", "value": "keep"},
+		},
+		"retention": map[string]interface{}{"group-by": "host,tags"},
+		"arguments": map[string]interface{}{"forget": []interface{}{"--compact"}},
+	})
+
+	g.Expect(profile.ForgetArguments()).Should(gomega.Equal([]string{
+		"--keep-daily", "7",
+		"--keep-within", "1y",
+		"--keep-tag", "keep",
+		"--group-by", "host,tags",
+		"--host", "nas",
+		"--path", "/home",
+		"--tag", "auto,home",
+		"--compact",
+	}))
+
+	// Scoping may be restricted (or, with an empty scope, disabled)
+	profile.viper.Set("retention.scope", []string{"paths"})
+	g.Expect(profile.ForgetArguments()).Should(gomega.ContainElement("--path"))
+	g.Expect(profile.ForgetArguments()).ShouldNot(gomega.ContainElement("--host"))
+
+	// Invalid policies prevent the profile from loading
+	invalid := NewProfileConfiguration()
+	err := invalid.applySettings(map[string]interface{}{
+		"keep-policy": []interface{}{map[string]interface{}{"period": "fortnightly", "value": 2}},
+	}, nil)
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring(`invalid period "fortnightly"`)))
+}
@@ -0,0 +1,157 @@
+package resticmanager
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// Prune modes, i.e., when the prune operation prunes the repository.
+const (
+	// PruneAlways prunes the repository on every run.
+	PruneAlways = "always"
+	// PruneNeeded prunes the repository only if snapshots were forgotten during
+	// the run, or if the unused space exceeds the prune threshold.
+	PruneNeeded = "needed"
+)
+
+// PruneModes lists the supported prune modes.
+var PruneModes = []string{PruneAlways, PruneNeeded}
+
+// PruneRule encapsulates the decision of whether the repository should be pruned.
+type PruneRule struct {
+	Mode string
+	// UnusedThreshold is the unused space above which the repository is pruned,
+	// either as a percentage of the repository size (e.g., "10%") or as a size (e.g., "50G").
+	UnusedThreshold string
+}
+
+// Validate returns an error if the rule mode or threshold is invalid.
+func (rule PruneRule) Validate() error {
+
+	if !containsString(PruneModes, rule.Mode) {
+		return fmt.Errorf("invalid prune mode %q (expected one of %s)", rule.Mode, strings.Join(PruneModes, ", "))
+	}
+
+	threshold := strings.TrimSpace(rule.UnusedThreshold)
+	if strings.HasSuffix(threshold, "%") {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(threshold, "%")), 64); err != nil {
+			return fmt.Errorf("invalid unused-threshold %q (e.g., 10%% or 50G)", rule.UnusedThreshold)
+		}
+		return nil
+	}
+
+	if _, err := sizeInBytes(threshold); err != nil {
+		return fmt.Errorf("invalid unused-threshold %q (e.g., 10%% or 50G)", rule.UnusedThreshold)
+	}
+
+	return nil
+}
+
+// Exceeded returns true if the unused space of a (dry-run) prune summary exceeds the rule threshold.
+func (rule PruneRule) Exceeded(summary *PruneSummary) bool {
+
+	threshold := strings.TrimSpace(rule.UnusedThreshold)
+
+	if strings.HasSuffix(threshold, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(threshold, "%")), 64)
+		if err != nil || summary.TotalBytes <= 0 {
+			return false
+		}
+		return 100*summary.UnusedBytes/summary.TotalBytes > percentage
+	}
+
+	size, err := sizeInBytes(threshold)
+	if err != nil {
+		return false
+	}
+
+	return summary.UnusedBytes > float64(size)
+}
+
+// PruneSummary encapsulates the summary reported by a prune operation.
+type PruneSummary struct {
+	// TotalBytes and UnusedBytes describe the repository before pruning.
+	TotalBytes  float64
+	UnusedBytes float64
+	// RepackedBytes is the amount of data repacked.
+	RepackedBytes float64
+	// ReclaimedBytes is the amount of space freed.
+	ReclaimedBytes float64
+	// RemainingBytes and RemainingUnusedBytes describe the repository after pruning.
+	RemainingBytes       float64
+	RemainingUnusedBytes float64
+	// DryRun is true if nothing was actually pruned (i.e., the summary is a preview).
+	DryRun bool
+}
+
+// NewPruneSummary creates and returns a new PruneSummary object, parsed from prune output.
+func NewPruneSummary(pruneText string) *PruneSummary {
+
+	summary := PruneSummary{}
+
+	summary.parse(pruneText)
+
+	return &summary
+}
+
+func (summary *PruneSummary) parse(pruneText string) {
+
+	/*
+	 We are looking for a section as follows:
+
+	   used:         7045 blobs / 31.155 MiB
+	   duplicates:      0 blobs / 0 B
+	   unused:         16 blobs / 10.412 KiB
+	   unreferenced:   0 B
+	   total:        7061 blobs / 31.166 MiB
+	   unused size: 0.03% of total size
+
+	   to repack:            69 blobs / 1.078 MiB
+	   this removes:         67 blobs / 1.047 MiB
+	   to delete:             7 blobs / 25.726 KiB
+	   total prune:          74 blobs / 1.072 MiB
+	   remaining:         16745 blobs / 38.003 MiB
+	   unused size after prune: 0 B (0.00% of remaining size)
+	*/
+
+	reBlobs := regexp.MustCompile(`^\s*(unused|total|to repack|total prune|remaining):\s*\d+\s+blobs\s*/\s*(\d+\.?\d*)\s+(\S+)`)
+	reUnusedAfter := regexp.MustCompile(`^\s*unused size after prune:\s*(\d+\.?\d*)\s+(\S+)`)
+
+	atof := func(s string, description string) float64 {
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			glog.Errorf("Error converting %s (%s): %v", description, s, err)
+		}
+		return value
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(pruneText))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := reBlobs.FindStringSubmatch(line); match != nil {
+			bytes := bytesFromUnits(atof(match[2], match[1]+" bytes"), match[3])
+			switch match[1] {
+			case "unused":
+				summary.UnusedBytes = bytes
+			case "total":
+				summary.TotalBytes = bytes
+			case "to repack":
+				summary.RepackedBytes = bytes
+			case "total prune":
+				summary.ReclaimedBytes = bytes
+			case "remaining":
+				summary.RemainingBytes = bytes
+			}
+		}
+
+		if match := reUnusedAfter.FindStringSubmatch(line); match != nil {
+			summary.RemainingUnusedBytes = bytesFromUnits(atof(match[1], "unused bytes after prune"), match[2])
+		}
+	}
+}
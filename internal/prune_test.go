@@ -0,0 +1,84 @@
+package resticmanager
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestPruneSummary(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	output := `loading indexes...
+loading all snapshots...
+finding data that is still in use for 12 snapshots
+[0:00] 100.00%  12 / 12 snapshots
+
+searching used packs...
+collecting packs for deletion and repacking
+[0:00] 100.00%  14 / 14 packs processed
+
+to repack:            69 blobs / 1.078 MiB
+this removes:         67 blobs / 1.047 MiB
+to delete:             7 blobs / 25.726 KiB
+total prune:          74 blobs / 1.072 MiB
+remaining:         16745 blobs / 38.003 MiB
+unused size after prune: 0 B (0.00% of remaining size)
+
+used:          16745 blobs / 38.003 MiB
+unused:           74 blobs / 1.072 MiB
+total:         16819 blobs / 39.075 MiB
+
+to keep:           8 packs
+to repack:         1 packs
+to delete:         2 packs
+`
+
+	summary := NewPruneSummary(output)
+	g.Expect(summary.RepackedBytes).Should(gomega.BeNumerically("~", 1.078*1024*1024))
+	g.Expect(summary.ReclaimedBytes).Should(gomega.BeNumerically("~", 1.072*1024*1024))
+	g.Expect(summary.RemainingBytes).Should(gomega.BeNumerically("~", 38.003*1024*1024))
+	g.Expect(summary.RemainingUnusedBytes).Should(gomega.BeZero())
+	g.Expect(summary.UnusedBytes).Should(gomega.BeNumerically("~", 1.072*1024*1024))
+	g.Expect(summary.TotalBytes).Should(gomega.BeNumerically("~", 39.075*1024*1024))
+}
+
+func TestPruneRule(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	summary := &PruneSummary{TotalBytes: 100 * 1024 * 1024 * 1024, UnusedBytes: 8 * 1024 * 1024 * 1024}
+
+	for threshold, exceeded := range map[string]bool{
+		"5%":     true,
+		"10%":    false,
+		"7.5%":   true,
+		"7G":     true,
+		"8G":     false,
+		"1T":     false,
+		"7.5gb":  true,
+		"8192mb": false,
+	} {
+		rule := PruneRule{Mode: PruneNeeded, UnusedThreshold: threshold}
+		g.Expect(rule.Validate()).Should(gomega.Succeed())
+		g.Expect(rule.Exceeded(summary)).Should(gomega.Equal(exceeded), threshold)
+	}
+
+	g.Expect(PruneRule{Mode: "sometimes", UnusedThreshold: "10%"}.Validate()).ShouldNot(gomega.Succeed())
+	g.Expect(PruneRule{Mode: PruneAlways, UnusedThreshold: "lots"}.Validate()).ShouldNot(gomega.Succeed())
+	g.Expect(PruneRule{Mode: PruneAlways, UnusedThreshold: "x%"}.Validate()).ShouldNot(gomega.Succeed())
+
+	profile := newTestProfile(map[string]interface{}{
+		"prune": map[string]interface{}{
+			"max-unused":            "5%",
+			"max-repack-size":       "50G",
+			"repack-cacheable-only": true,
+		},
+		"arguments": map[string]interface{}{"prune": []interface{}{"--verbose"}},
+	})
+	g.Expect(profile.PruneRule()).Should(gomega.Equal(PruneRule{Mode: PruneNeeded, UnusedThreshold: "10%"}))
+	g.Expect(profile.PruneArguments()).Should(gomega.Equal([]string{
+		"--max-unused", "5%", "--max-repack-size", "50G", "--repack-cacheable-only", "--verbose",
+	}))
+}
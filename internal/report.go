@@ -0,0 +1,310 @@
+package resticmanager
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// Operation status values, as recorded in an OperationReport.
+const (
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationSkipped   = "skipped"
+)
+
+// ProfileReport encapsulates the identifying details of a processed profile.
+type ProfileReport struct {
+	Name       string
+	File       string
+	Tags       []string
+	Source     string
+	Repository string
+}
+
+// OperationReport encapsulates the outcome of a single profile operation (e.g., "backup").
+type OperationReport struct {
+	Name     string
+	Status   string
+	Start    time.Time
+	Duration time.Duration
+	Error    string
+}
+
+// Finish marks the operation as complete, recording its duration and (if
+// err is not nil) its failure.
+func (operation *OperationReport) Finish(err error) {
+
+	operation.Duration = time.Since(operation.Start)
+
+	if err != nil {
+		operation.Status = OperationFailed
+		operation.Error = err.Error()
+	} else {
+		operation.Status = OperationSucceeded
+	}
+}
+
+// Skip marks the operation as skipped (i.e., not required).
+func (operation *OperationReport) Skip() {
+
+	operation.Duration = time.Since(operation.Start)
+	operation.Status = OperationSkipped
+}
+
+// Report encapsulates the outcome of processing a profile. It is the data
+// model made available to email templates, e.g., as {{.Profile.Name}} or
+// {{range .Operations}}{{.Name}}: {{.Status}}{{end}}.
+type Report struct {
+	// Preamble is an introductory note (e.g., describing the log level filter in effect).
+	Preamble string
+	// LogSummary holds the number of logged messages at each level.
+	LogSummary []*glog.RecordSummary
+	// LogRecords holds the logged messages at or above the email log level.
+	LogRecords []glog.Record
+
+	// Profile identifies the processed profile.
+	Profile ProfileReport
+
+	// Start, End and Duration describe when (and for how long) the profile was processed.
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+
+	// Operations holds the outcome of each performed operation, in sequence order.
+	Operations []*OperationReport
+
+	// Backup holds the parsed backup summary, if a backup was performed.
+	Backup *BackupSummary
+	// Diff holds the difference between the two most-recent snapshots, if a diff was performed.
+	Diff *SnapshotDiff
+	// Anomalies describes the anomalies detected in the diff, if any.
+	Anomalies []string
+	// Prune holds the parsed prune summary, if a prune was performed (or previewed, if not needed).
+	Prune *PruneSummary
+	// Verify holds the outcome of the restore drill, if one was performed.
+	Verify *RestoreVerification
+	// Snapshots holds the repository snapshot list (oldest first), if available.
+	Snapshots []Snapshot
+	// Pins holds the pinned snapshots (oldest first), if available.
+	Pins []Pin
+
+	// Hostname and Version identify the host and the restic-manager version.
+	Hostname string
+	Version  string
+}
+
+// NewReport creates and returns a new Report for the specified profile, started now.
+func NewReport(profile *ProfileConfiguration) *Report {
+
+	hostname, _ := os.Hostname()
+
+	report := &Report{
+		Start:      time.Now(),
+		Operations: make([]*OperationReport, 0),
+		Hostname:   hostname,
+		Version:    VersionGitCommit,
+	}
+
+	if profile != nil {
+		report.Profile = ProfileReport{
+			Name:       profile.Name(),
+			File:       profile.File(),
+			Tags:       profile.Tags(),
+			Source:     profile.Source(),
+			Repository: profile.Repository(),
+		}
+	}
+
+	return report
+}
+
+// NewSyntheticReport creates and returns a Report for the specified profile,
+// populated with plausible (but entirely fictitious) data. It is intended for
+// previewing email templates without performing any restic operations.
+func NewSyntheticReport(profile *ProfileConfiguration) *Report {
+
+	report := NewReport(profile)
+
+	now := time.Now()
+	report.Start = now.Add(-3 * time.Minute)
+
+	for i, name := range []string{"unlock", "backup", "check", "apply-retention", "diff"} {
+		report.Operations = append(report.Operations, &OperationReport{
+			Name:     name,
+			Status:   OperationSucceeded,
+			Start:    report.Start.Add(time.Duration(i) * 30 * time.Second),
+			Duration: time.Duration(i+1) * 7 * time.Second,
+		})
+	}
+
+	report.Backup = &BackupSummary{
+		FilesNew:        12,
+		FilesChanged:    3,
+		FilesUnmodified: 1024,
+		DirsNew:         2,
+		DirsChanged:     5,
+		DirsUnmodified:  128,
+		BytesAdded:      42 * 1024 * 1024,
+		FilesProcessed:  1039,
+		BytesProcessed:  3.5 * 1024 * 1024 * 1024,
+		Duration:        "0:42",
+		SnapshotID:      "1a2b3c4d",
+	}
+
+	report.Diff = &SnapshotDiff{
+		FilesNew:     12,
+		FilesRemoved: 1,
+		FilesChanged: 3,
+		DirsNew:      2,
+		BytesAdded:   42 * 1024 * 1024,
+		BytesRemoved: 1024,
+	}
+
+	report.Anomalies = []string{
+		"Files changed (1204) is 8.2 standard deviations above the baseline mean (31 over 20 runs)",
+	}
+
+	report.Prune = &PruneSummary{
+		TotalBytes:           3 * 1024 * 1024 * 1024 * 1024,
+		UnusedBytes:          420 * 1024 * 1024 * 1024,
+		RepackedBytes:        12 * 1024 * 1024 * 1024,
+		ReclaimedBytes:       380 * 1024 * 1024 * 1024,
+		RemainingBytes:       2.6 * 1024 * 1024 * 1024 * 1024,
+		RemainingUnusedBytes: 40 * 1024 * 1024 * 1024,
+	}
+
+	report.Verify = &RestoreVerification{
+		Snapshot: "1a2b3c4d",
+		Files: []FileVerification{
+			{Path: report.Profile.Source + "/documents/report.odt", Outcome: VerifyMatched},
+			{Path: report.Profile.Source + "/documents/notes.txt", Outcome: VerifyDrifted, Detail: "modified since the snapshot"},
+		},
+	}
+
+	for i := 3; i >= 0; i-- {
+		id := fmt.Sprintf("%08x", 0x1a2b3c4d-i)
+		report.Snapshots = append(report.Snapshots, Snapshot{
+			ID:       id,
+			ShortID:  id,
+			Time:     now.AddDate(0, 0, -i),
+			Hostname: report.Hostname,
+			Paths:    []string{report.Profile.Source},
+		})
+	}
+
+	pinned := report.Snapshots[0]
+	pinned.Tags = PinTags(now.AddDate(0, 6, 0), "Before storage migration")
+	report.Snapshots[0] = pinned
+	report.Pins = PinsOf([]Snapshot{pinned})
+
+	levels := glog.ListLogLevels()
+	for i, level := range levels {
+		report.LogRecords = append(report.LogRecords, glog.Record{
+			Time:    report.Start.Add(time.Duration(i) * time.Second),
+			Level:   level,
+			Message: fmt.Sprintf("Example message at level %s", level),
+		})
+		report.LogSummary = append(report.LogSummary, &glog.RecordSummary{
+			Level: level,
+			Count: 1,
+		})
+	}
+
+	report.Finish()
+
+	return report
+}
+
+// StartOperation records the start of a named operation.
+func (report *Report) StartOperation(name string) *OperationReport {
+
+	operation := &OperationReport{
+		Name:  name,
+		Start: time.Now(),
+	}
+
+	report.Operations = append(report.Operations, operation)
+
+	return operation
+}
+
+// Finish marks the report as complete.
+func (report *Report) Finish() {
+
+	report.End = time.Now()
+	report.Duration = report.End.Sub(report.Start)
+}
+
+// Succeeded returns true if no operation failed.
+func (report *Report) Succeeded() bool {
+
+	for _, operation := range report.Operations {
+		if operation.Status == OperationFailed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HumanBytes formats a byte count using binary (IEC) units, as restic does.
+func HumanBytes(value interface{}) string {
+
+	var bytes float64
+
+	switch v := value.(type) {
+	case float64:
+		bytes = v
+	case float32:
+		bytes = float64(v)
+	case int:
+		bytes = float64(v)
+	case int64:
+		bytes = float64(v)
+	case uint:
+		bytes = float64(v)
+	case uint64:
+		bytes = float64(v)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+
+	index := 0
+	for (bytes >= 1024 || bytes <= -1024) && index < len(units)-1 {
+		bytes /= 1024
+		index++
+	}
+
+	if index == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[index])
+	}
+
+	return fmt.Sprintf("%.3f %s", bytes, units[index])
+}
+
+// HumanDuration formats a duration rounded to a sensible precision, e.g., "1h2m3s" or "450ms".
+func HumanDuration(duration time.Duration) string {
+
+	switch {
+	case duration >= time.Minute:
+		return duration.Round(time.Second).String()
+	case duration >= time.Second:
+		return duration.Round(10 * time.Millisecond).String()
+	default:
+		return duration.Round(time.Millisecond).String()
+	}
+}
+
+// TemplateFunctions returns the set of helper functions available to (email) templates.
+func TemplateFunctions() map[string]interface{} {
+
+	return map[string]interface{}{
+		"humanBytes":    HumanBytes,
+		"humanDuration": HumanDuration,
+	}
+}
@@ -0,0 +1,49 @@
+package resticmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestBackupSummary(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	summary := NewBackupSummary(`
+open repository
+Files:           5 new,     2 changed,    11 unmodified
+Dirs:            3 new,     1 changed,     4 unmodified
+Data Blobs:      5 new
+Tree Blobs:      4 new
+Added to the repo: 1.500 MiB
+
+processed 18 files, 27.734 MiB in 0:03
+snapshot 1a2b3c4d saved
+`)
+
+	g.Expect(summary.FilesNew).Should(gomega.Equal(5))
+	g.Expect(summary.FilesChanged).Should(gomega.Equal(2))
+	g.Expect(summary.FilesUnmodified).Should(gomega.Equal(11))
+	g.Expect(summary.DirsNew).Should(gomega.Equal(3))
+	g.Expect(summary.DirsChanged).Should(gomega.Equal(1))
+	g.Expect(summary.DirsUnmodified).Should(gomega.Equal(4))
+	g.Expect(summary.BytesAdded).Should(gomega.Equal(1.5 * 1024 * 1024))
+	g.Expect(summary.FilesProcessed).Should(gomega.Equal(18))
+	g.Expect(summary.Duration).Should(gomega.Equal("0:03"))
+	g.Expect(summary.SnapshotID).Should(gomega.Equal("1a2b3c4d"))
+}
+
+func TestTemplateHelpers(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(HumanBytes(512)).Should(gomega.Equal("512 B"))
+	g.Expect(HumanBytes(1.5 * 1024 * 1024)).Should(gomega.Equal("1.500 MiB"))
+	g.Expect(HumanBytes(int64(3) << 40)).Should(gomega.Equal("3.000 TiB"))
+
+	g.Expect(HumanDuration(1234567 * time.Microsecond)).Should(gomega.Equal("1.23s"))
+	g.Expect(HumanDuration(90*time.Minute + 1500*time.Millisecond)).Should(gomega.Equal("1h30m2s"))
+	g.Expect(HumanDuration(450 * time.Millisecond)).Should(gomega.Equal("450ms"))
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"os/exec"
 	"regexp"
@@ -21,6 +23,11 @@ import (
 type Restic struct {
 	executable string
 	rawLog     io.Writer
+	output     bytes.Buffer
+	// forgotten counts the snapshots forgotten by retention policy application (see PruneIfNeeded).
+	forgotten int
+	// repositoryID is the ID of the repository, once accepted (see CheckRepositoryIdentity).
+	repositoryID string
 }
 
 // NewRestic creates and returns a new Restic object.
@@ -97,12 +104,20 @@ func (restic *Restic) execute(command string, arguments []string, profile *Profi
 	stdout := rawStdout.String()
 	stderr := rawStderr.String()
 
+	now := time.Now()
+
+	// Retain (unfiltered, but redacted) output for later inspection
+	restic.output.WriteString(RedactString(fmt.Sprintf("\n==== %s %v %s\n", command, arguments, now)))
+	restic.output.WriteString(fmt.Sprintf("\nSTDOUT %s\n", now))
+	restic.output.WriteString(RedactString(stdout))
+	restic.output.WriteString(fmt.Sprintf("\nSTDERR %s\n", now))
+	restic.output.WriteString(RedactString(stderr))
+
 	if restic.rawLog != nil {
-		now := time.Now()
 		restic.rawLog.Write([]byte(fmt.Sprintf("\nSTDOUT %s\n", now)))
-		restic.rawLog.Write(rawStdout.Bytes())
+		restic.rawLog.Write([]byte(RedactString(stdout)))
 		restic.rawLog.Write([]byte(fmt.Sprintf("\nSTDERR %s\n", now)))
-		restic.rawLog.Write(rawStderr.Bytes())
+		restic.rawLog.Write([]byte(RedactString(stderr)))
 	}
 
 	// Remove odd/mangled characters.
@@ -137,6 +152,11 @@ func (restic *Restic) execute(command string, arguments []string, profile *Profi
 	return stdout, stderr, err
 }
 
+// Output returns the raw (unfiltered) output of all restic operations performed by this Restic object.
+func (restic *Restic) Output() []byte {
+	return restic.output.Bytes()
+}
+
 // RepoExists tests for the existence of repository
 func (restic *Restic) RepoExists(profile *ProfileConfiguration) (bool, error) {
 
@@ -199,15 +219,36 @@ func (restic *Restic) Initialise(profile *ProfileConfiguration) (string, error)
 	)
 }
 
-// Backup performs a restic backup operation
+// Backup performs a restic backup operation, then asserts the content of the
+// new snapshot (see AssertContents); a failed assertion fails the backup. The
+// backup is skipped (and fails) if the source fails its pre-flight gates, or
+// if the repository identity is not accepted (see CheckRepositoryIdentity).
 func (restic *Restic) Backup(profile *ProfileConfiguration) (string, error) {
 
 	glog.Noticef("Performing backup of %v", profile.Source())
 
+	if err := profile.Preflight(); err != nil {
+		return "", fmt.Errorf("%v; backup skipped", err)
+	}
+
+	if restic.repositoryID == "" {
+		if err := restic.CheckRepositoryIdentity(profile, true); err != nil {
+			return "", fmt.Errorf("%v; backup skipped", err)
+		}
+	}
+
 	arguments := make([]string, 0)
 
 	arguments = append(arguments, "--verbose=8")
 
+	// Record the host and tags by which retention policy application is scoped
+	if profile.viper.IsSet("hostname") {
+		arguments = append(arguments, "--host", profile.Hostname())
+	}
+	for _, tag := range profile.SnapshotTags() {
+		arguments = append(arguments, "--tag", tag)
+	}
+
 	// Add additional profile arguments
 	arguments = append(arguments, profile.Arguments("backup")...)
 
@@ -219,10 +260,6 @@ func (restic *Restic) Backup(profile *ProfileConfiguration) (string, error) {
 			continue
 		}
 
-		// TODO: Deprecate/remove this method of substitution.
-		// Expand/substitute source directory
-		e = strings.Replace(e, "<source>", profile.Source(), -1)
-
 		arguments = append(
 			arguments,
 			fmt.Sprintf("--exclude=%s", e),
@@ -238,16 +275,118 @@ func (restic *Restic) Backup(profile *ProfileConfiguration) (string, error) {
 		return stdout, errors.New(stderr)
 	}
 
+	if restic.repositoryID != "" && !AppConfig.DryRun {
+		if err := restic.recordBackup(profile); err != nil {
+			glog.Warningf("%v", err)
+		}
+	}
+
+	// Assert the content of the new snapshot
+	if snapshot := NewBackupSummary(stdout).SnapshotID; snapshot != "" {
+		if err := restic.AssertContents(profile, snapshot); err != nil {
+			return stdout, err
+		}
+	}
+
 	return stdout, nil
 }
 
-// Check performs a restic check operation
+// Check performs a restic check operation, reading (none, one or, within
+// the time budget, several parts of) the pack data per the profile check
+// mode. Data verification progress is recorded per repository, so that
+// successive checks rotate through the parts.
 func (restic *Restic) Check(profile *ProfileConfiguration) (string, error) {
 
-	return restic.simpleRepoOperation(profile,
-		"check",
-		"Checking repository",
-	)
+	glog.Noticef("Checking repository %v", profile.Repository())
+
+	mode := profile.CheckMode()
+	if mode == CheckMetadata {
+		stdout, stderr, err := restic.execute("check", profile.Arguments("check"), profile)
+		if err != nil {
+			return stdout, errors.New(stderr)
+		}
+		return stdout, restic.recordCheck(profile, nil)
+	}
+
+	state, err := AppConfig.LoadCheckState(restic.checkedRepositoryID(profile), profile.Repository())
+	if err != nil {
+		return "", err
+	}
+	state.reset(profile.CheckSubsets())
+
+	start := time.Now()
+	output := ""
+	for read := 0; read < len(state.Parts); read++ {
+
+		if mode == CheckBudget && read > 0 && time.Since(start) >= profile.CheckBudget() {
+			glog.Infof("Check time budget (%v) exhausted", profile.CheckBudget())
+			break
+		}
+
+		part := state.NextPart()
+		glog.Infof("Reading pack data part %s", part)
+
+		arguments := append([]string{"--read-data-subset", part}, profile.Arguments("check")...)
+		stdout, stderr, err := restic.execute("check", arguments, profile)
+		output += stdout
+		if err != nil {
+			// Progress so far is retained; the failed part is read again next time
+			if !AppConfig.DryRun {
+				if saveErr := AppConfig.SaveCheckState(state); saveErr != nil {
+					glog.Warningf("Could not save check state: %v", saveErr)
+				}
+			}
+			return output, fmt.Errorf("Check of part %s failed: %s", part, stderr)
+		}
+
+		state.markRead(time.Now())
+
+		if mode == CheckSubset {
+			break
+		}
+	}
+
+	return output, restic.recordCheck(profile, state)
+}
+
+// checkedRepositoryID returns the ID of the checked repository, by which its
+// check state is recorded: the accepted ID (see CheckRepositoryIdentity) or,
+// if there is none, that retrieved from the repository (empty if it cannot be).
+func (restic *Restic) checkedRepositoryID(profile *ProfileConfiguration) string {
+
+	if restic.repositoryID != "" {
+		return restic.repositoryID
+	}
+
+	id, err := restic.RepositoryID(profile)
+	if err != nil {
+		glog.Debugf("Check state is recorded by repository location: %v", err)
+		return ""
+	}
+
+	return id
+}
+
+// recordCheck records a successful check, along with (if not nil) the data verification progress.
+func (restic *Restic) recordCheck(profile *ProfileConfiguration, state *CheckState) error {
+
+	if AppConfig.DryRun {
+		return nil
+	}
+
+	if state == nil {
+		var err error
+		if state, err = AppConfig.LoadCheckState(restic.checkedRepositoryID(profile), profile.Repository()); err != nil {
+			return err
+		}
+	}
+	state.LastCheck = time.Now()
+
+	if err := AppConfig.SaveCheckState(state); err != nil {
+		return fmt.Errorf("Could not save check state: %v", err)
+	}
+
+	return nil
 }
 
 // Unlock performs a restic unlock operation
@@ -289,20 +428,61 @@ func (restic *Restic) Ls(profile *ProfileConfiguration, snapshot string) (string
 	return stdout, nil
 }
 
-// ApplyRetentionPolicy performs a restic forget operation
+// RetentionPlan previews the application of the profile retention policies
+// (see ProfileConfiguration.ForgetArguments), via "restic forget --dry-run --json".
+func (restic *Restic) RetentionPlan(profile *ProfileConfiguration) (*RetentionPlan, error) {
+
+	arguments := append(profile.ForgetArguments(), "--dry-run", "--json")
+
+	stdout, stderr, err := restic.execute("forget", arguments, profile)
+
+	if err != nil {
+		return nil, fmt.Errorf("Could not preview retention policy application: %v: %s", err, stderr)
+	}
+
+	plan, err := ParseRetentionPlan(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.ExpiredPins = applyPins(plan, time.Now())
+
+	return plan, nil
+}
+
+// ApplyRetentionPolicy performs a restic forget operation, scoped to the profile
+// snapshots (see ProfileConfiguration.ForgetArguments). The outcome is first
+// previewed, and the operation refused if it would violate the profile retention
+// guard or remove a pinned snapshot; otherwise, exactly the previewed snapshots
+// are forgotten. Expired pins are then removed from the remaining snapshots.
 func (restic *Restic) ApplyRetentionPolicy(profile *ProfileConfiguration) (string, error) {
 
 	glog.Noticef("Performing retention policy application for %v", profile.Repository())
 
-	arguments := make([]string, 0)
+	if len(profile.RetentionScope()) == 0 {
+		glog.Warningf("Retention policy application is not scoped; it applies to all snapshots in %v", profile.Repository())
+	}
 
-	// Compose a set of keep options
-	for _, p := range profile.RetentionPolicies() {
-		arguments = append(
-			arguments,
-			fmt.Sprintf("--keep-%s", p.Period),
-			fmt.Sprintf("%d", p.Value),
-		)
+	plan, err := restic.RetentionPlan(profile)
+	if err != nil {
+		return "", err
+	}
+
+	removed := plan.Removed()
+	glog.Infof("Retention policy keeps %d and removes %d of %d snapshot(s).", len(plan.Decisions)-len(removed), len(removed), len(plan.Decisions))
+
+	if err := profile.CheckRetentionPlan(plan, time.Now()); err != nil {
+		return "", fmt.Errorf("Refusing to apply retention policy: %v", err)
+	}
+
+	if len(removed) == 0 {
+		return "", restic.unpinExpired(profile, plan)
+	}
+
+	arguments := append([]string{}, profile.Arguments("forget")...)
+	for _, snapshot := range removed {
+		glog.Infof("Removing snapshot %s (%s)", snapshot.ShortID, snapshot.Time.Format("2006-01-02 15:04:05"))
+		arguments = append(arguments, snapshot.ID)
 	}
 
 	stdout, stderr, err := restic.execute("forget", arguments, profile)
@@ -311,16 +491,103 @@ func (restic *Restic) ApplyRetentionPolicy(profile *ProfileConfiguration) (strin
 		return stdout, errors.New(stderr)
 	}
 
-	return stdout, nil
+	restic.forgotten += len(removed)
+
+	return stdout, restic.unpinExpired(profile, plan)
 }
 
-// Clean performs a restic prune operation
+// unpinExpired unpins the snapshots kept by an (applied) retention plan whose
+// pins have expired. Forgotten snapshots are left alone, as tagging a snapshot
+// replaces it (i.e., changes its ID).
+func (restic *Restic) unpinExpired(profile *ProfileConfiguration, plan *RetentionPlan) error {
+
+	removed := make(map[string]bool)
+	for _, snapshot := range plan.Removed() {
+		removed[snapshot.ID] = true
+	}
+
+	for _, pin := range plan.ExpiredPins {
+
+		if removed[pin.Snapshot.ID] {
+			continue
+		}
+
+		glog.Noticef("Pin on snapshot %s expired on %s; unpinning", pin.Snapshot.ShortID, pin.Expiry())
+		if err := restic.Unpin(profile, pin.Snapshot); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clean performs a restic prune operation, with the profile prune options
+// (see ProfileConfiguration.PruneArguments).
 func (restic *Restic) Clean(profile *ProfileConfiguration) (string, error) {
 
-	return restic.simpleRepoOperation(profile,
-		"prune",
-		"Cleaning repository",
-	)
+	_, stdout, err := restic.Prune(profile, false)
+
+	return stdout, err
+}
+
+// Prune performs a restic prune operation (or, if dryRun, previews it), with
+// the profile prune options, returning the parsed summary along with the output.
+func (restic *Restic) Prune(profile *ProfileConfiguration, dryRun bool) (*PruneSummary, string, error) {
+
+	glog.Noticef("Cleaning repository %v", profile.Repository())
+
+	arguments := profile.PruneArguments()
+	if dryRun {
+		arguments = append(arguments, "--dry-run")
+	}
+
+	stdout, stderr, err := restic.execute("prune", arguments, profile)
+
+	if err != nil {
+		return nil, stdout, errors.New(stderr)
+	}
+
+	summary := NewPruneSummary(stdout)
+	summary.DryRun = dryRun
+
+	return summary, stdout, nil
+}
+
+// PruneIfNeeded performs a restic prune operation if the profile prune rule
+// (see ProfileConfiguration.PruneRule) requires it: always, or if snapshots have
+// been forgotten (by this object) or the unused space exceeds the rule threshold,
+// as previewed by a dry-run. The returned summary is that of the dry-run if the
+// repository was not pruned.
+func (restic *Restic) PruneIfNeeded(profile *ProfileConfiguration) (*PruneSummary, bool, error) {
+
+	rule := profile.PruneRule()
+
+	if rule.Mode == PruneNeeded && restic.forgotten == 0 {
+
+		preview, _, err := restic.Prune(profile, true)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !rule.Exceeded(preview) {
+			glog.Infof("No snapshots forgotten and unused space (%s of %s) within %s; not pruning.",
+				HumanBytes(preview.UnusedBytes), HumanBytes(preview.TotalBytes), rule.UnusedThreshold)
+			return preview, false, nil
+		}
+
+		glog.Infof("Unused space (%s of %s) exceeds %s; pruning.",
+			HumanBytes(preview.UnusedBytes), HumanBytes(preview.TotalBytes), rule.UnusedThreshold)
+	}
+
+	summary, stdout, err := restic.Prune(profile, false)
+	if err != nil {
+		return nil, false, err
+	}
+	glog.Infof("%s", stdout)
+
+	glog.Infof("Pruning reclaimed %s.", HumanBytes(summary.ReclaimedBytes))
+
+	return summary, true, nil
 }
 
 // RebuildIndex performs a restic rebuild-index operation
@@ -633,3 +900,451 @@ func (diff *SnapshotDiff) parse(diffText string) {
 		}
 	}
 }
+
+// bytesFromUnits converts a value expressed in the specified (binary) units, as displayed by restic, to bytes.
+func bytesFromUnits(value float64, unit string) float64 {
+
+	switch unit {
+	case "KiB":
+		value *= 1024
+	case "MiB":
+		value *= 1024 * 1024
+	case "GiB":
+		value *= 1024 * 1024 * 1024
+	case "TiB":
+		value *= 1024 * 1024 * 1024 * 1024
+	}
+
+	return value
+}
+
+// BackupSummary encapsulates the summary reported by a backup operation
+type BackupSummary struct {
+	FilesNew        int
+	FilesChanged    int
+	FilesUnmodified int
+	DirsNew         int
+	DirsChanged     int
+	DirsUnmodified  int
+	BytesAdded      float64
+	FilesProcessed  int
+	BytesProcessed  float64
+	Duration        string
+	SnapshotID      string
+}
+
+// NewBackupSummary creates and returns a new BackupSummary object, parsed from backup output.
+func NewBackupSummary(backupText string) *BackupSummary {
+
+	summary := BackupSummary{}
+
+	summary.parse(backupText)
+
+	return &summary
+}
+
+func (summary *BackupSummary) parse(backupText string) {
+
+	/*
+	 We are looking for a section as follows:
+
+	   Files:           5 new,     0 changed,     0 unmodified
+	   Dirs:            3 new,     0 changed,     0 unmodified
+	   Added to the repo: 1.234 MiB
+
+	   processed 5 files, 1.172 MiB in 0:00
+	   snapshot 1a2b3c4d saved
+	*/
+
+	reFiles := regexp.MustCompile(`Files:\s*(\d+)\s+new,\s*(\d+)\s+changed,\s*(\d+)\s+unmodified`)
+	reDirs := regexp.MustCompile(`Dirs:\s*(\d+)\s+new,\s*(\d+)\s+changed,\s*(\d+)\s+unmodified`)
+	reAdded := regexp.MustCompile(`Added to the repo(?:sitory)?:\s*(\d+\.?\d*)\s+(\S+)`)
+	reProcessed := regexp.MustCompile(`processed\s+(\d+)\s+files,\s*(\d+\.?\d*)\s+(\S+)\s+in\s+(\S+)`)
+	reSnapshot := regexp.MustCompile(`snapshot\s+([0-9a-f]+)\s+saved`)
+
+	atoi := func(s string, description string) int {
+		count, err := strconv.Atoi(s)
+		if err != nil {
+			glog.Errorf("Error converting %s (%s): %v", description, s, err)
+		}
+		return count
+	}
+
+	atof := func(s string, description string) float64 {
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			glog.Errorf("Error converting %s (%s): %v", description, s, err)
+		}
+		return value
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(backupText))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := reFiles.FindStringSubmatch(line); match != nil {
+			summary.FilesNew = atoi(match[1], "new-file count")
+			summary.FilesChanged = atoi(match[2], "changed-file count")
+			summary.FilesUnmodified = atoi(match[3], "unmodified-file count")
+		}
+
+		if match := reDirs.FindStringSubmatch(line); match != nil {
+			summary.DirsNew = atoi(match[1], "new-dir count")
+			summary.DirsChanged = atoi(match[2], "changed-dir count")
+			summary.DirsUnmodified = atoi(match[3], "unmodified-dir count")
+		}
+
+		if match := reAdded.FindStringSubmatch(line); match != nil {
+			summary.BytesAdded = bytesFromUnits(atof(match[1], "added bytes"), match[2])
+		}
+
+		if match := reProcessed.FindStringSubmatch(line); match != nil {
+			summary.FilesProcessed = atoi(match[1], "processed-file count")
+			summary.BytesProcessed = bytesFromUnits(atof(match[2], "processed bytes"), match[3])
+			summary.Duration = match[4]
+		}
+
+		if match := reSnapshot.FindStringSubmatch(line); match != nil {
+			summary.SnapshotID = match[1]
+		}
+	}
+}
+
+// Snapshot encapsulates a single snapshot, as listed by restic
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Username string    `json:"username"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags"`
+}
+
+// SnapshotList retrieves the list of snapshots in the repository, oldest first.
+func (restic *Restic) SnapshotList(profile *ProfileConfiguration) ([]Snapshot, error) {
+
+	glog.Infof("Retrieving snapshot list for repository at %v", profile.Repository())
+
+	arguments := []string{"--json"}
+
+	stdout, stderr, err := restic.execute("snapshots", arguments, profile)
+
+	if err != nil {
+		return nil, fmt.Errorf("Could not list snapshots: %v: %s", err, stderr)
+	}
+
+	var snapshots []Snapshot
+
+	if strings.TrimSpace(stdout) == "" {
+		// e.g., a dry-run
+		return snapshots, nil
+	}
+
+	if err := json.Unmarshal([]byte(stdout), &snapshots); err != nil {
+		return nil, fmt.Errorf("Could not parse snapshot list: %v", err)
+	}
+
+	return snapshots, nil
+}
+
+// Tag performs a restic tag operation, adding and removing tags on the specified snapshots.
+func (restic *Restic) Tag(profile *ProfileConfiguration, ids []string, add []string, remove []string) (string, error) {
+
+	glog.Infof("Tagging snapshot(s) %v in repository at %v", ids, profile.Repository())
+
+	arguments := make([]string, 0)
+	for _, tag := range add {
+		arguments = append(arguments, "--add", tag)
+	}
+	for _, tag := range remove {
+		arguments = append(arguments, "--remove", tag)
+	}
+	arguments = append(arguments, ids...)
+
+	stdout, stderr, err := restic.execute("tag", arguments, profile)
+
+	if err != nil {
+		return stdout, fmt.Errorf("Could not tag snapshot(s): %v: %s", err, stderr)
+	}
+
+	return stdout, nil
+}
+
+// Pins retrieves the pinned snapshots in the repository, oldest first.
+func (restic *Restic) Pins(profile *ProfileConfiguration) ([]Pin, error) {
+
+	snapshots, err := restic.SnapshotList(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return PinsOf(snapshots), nil
+}
+
+// Pin pins a snapshot, replacing any existing pin (and, thus, its expiry date and note).
+func (restic *Restic) Pin(profile *ProfileConfiguration, snapshot Snapshot, until time.Time, note string) error {
+
+	add, remove := pinTagChanges(snapshot, until, note)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	_, err := restic.Tag(profile, []string{snapshot.ID}, add, remove)
+
+	return err
+}
+
+// Unpin removes the pin from a snapshot.
+func (restic *Restic) Unpin(profile *ProfileConfiguration, snapshot Snapshot) error {
+
+	tags := pinTagsOf(snapshot)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err := restic.Tag(profile, []string{snapshot.ID}, nil, tags)
+
+	return err
+}
+
+// Nodes retrieves the entries of a snapshot, via "restic ls --json".
+func (restic *Restic) Nodes(profile *ProfileConfiguration, snapshot string) ([]Node, error) {
+
+	glog.Infof("Listing snapshot %s in repository at %v", snapshot, profile.Repository())
+
+	stdout, stderr, err := restic.execute("ls", []string{"--json", snapshot}, profile)
+
+	if err != nil {
+		return nil, fmt.Errorf("Could not list snapshot %s: %v: %s", snapshot, err, stderr)
+	}
+
+	return ParseNodes(stdout)
+}
+
+// LatestSnapshot retrieves the most-recent snapshot of the profile (i.e., with
+// its hostname and source path), or nil if there is none.
+func (restic *Restic) LatestSnapshot(profile *ProfileConfiguration) (*Snapshot, error) {
+
+	snapshots, err := restic.SnapshotList(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return LatestSnapshot(snapshots, profile.Hostname(), profile.snapshotPath()), nil
+}
+
+// FindSnapshot retrieves the snapshot of the profile with the specified ID or,
+// for "latest", the most-recent snapshot of the profile (i.e., with its hostname
+// and source path, rather than, e.g., that of another host sharing the
+// repository); see FindSnapshot.
+func (restic *Restic) FindSnapshot(profile *ProfileConfiguration, id string) (*Snapshot, error) {
+
+	snapshots, err := restic.SnapshotList(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := FindSnapshot(snapshots, id, profile.Hostname(), profile.snapshotPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot == nil {
+		return nil, fmt.Errorf("No snapshot %s of profile %s in %s", id, profile.Name(), RedactString(profile.Repository()))
+	}
+
+	return snapshot, nil
+}
+
+// VerifyRestore performs a restore drill: the critical paths (see
+// ProfileConfiguration.VerifyRestorePaths) and a random sample of other files
+// are restored from the latest snapshot into a temporary directory (beneath
+// the application Tempdir), and compared with the snapshot and the live source.
+// Files changed since the snapshot are expected drift; any other difference is
+// a mismatch, reported as an error. The temporary directory is removed afterwards.
+func (restic *Restic) VerifyRestore(profile *ProfileConfiguration) (*RestoreVerification, error) {
+
+	glog.Noticef("Verifying restore from repository at %v", profile.Repository())
+
+	if AppConfig.DryRun {
+		glog.Infof("Dry-run; no action will be performed.")
+		return nil, nil
+	}
+
+	snapshot, err := restic.LatestSnapshot(profile)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("No snapshot of %s (host %s) to restore", profile.Source(), profile.Hostname())
+	}
+
+	nodes, err := restic.Nodes(profile, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	selected := selectFiles(nodes, profile.VerifyRestorePaths(), profile.VerifyRestoreSample(), random)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("Snapshot %s contains no files to restore", snapshot.ShortID)
+	}
+
+	target, err := ioutil.TempDir(AppConfig.Tempdir(), "verify-restore-")
+	if err != nil {
+		return nil, fmt.Errorf("Could not create restore directory: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(target); err != nil {
+			glog.Warningf("Could not remove restore directory %s: %v", target, err)
+		}
+	}()
+
+	glog.Infof("Restoring %d file(s) from snapshot %s into %s", len(selected), snapshot.ShortID, target)
+
+	arguments := []string{snapshot.ID, "--target", target}
+	for _, node := range selected {
+		arguments = append(arguments, "--include", escapePattern(node.Path))
+	}
+	arguments = append(arguments, profile.Arguments("restore")...)
+
+	if _, stderr, err := restic.execute("restore", arguments, profile); err != nil {
+		return nil, fmt.Errorf("Could not restore from snapshot %s: %v: %s", snapshot.ShortID, err, stderr)
+	}
+
+	verification := verifyRestored(snapshot.ShortID, selected, target)
+
+	for _, file := range verification.Files {
+		switch file.Outcome {
+		case VerifyMismatched:
+			glog.Errorf("Restored %s: %s", file.Path, file.Detail)
+		case VerifyDrifted:
+			glog.Infof("Restored %s: %s (expected drift)", file.Path, file.Detail)
+		default:
+			glog.Debugf("Restored %s: %s", file.Path, file.Outcome)
+		}
+	}
+
+	glog.Infof("Restore verification of snapshot %s: %d matched, %d drifted, %d mismatched.", snapshot.ShortID,
+		verification.Count(VerifyMatched), verification.Count(VerifyDrifted), verification.Count(VerifyMismatched))
+
+	if mismatches := verification.Mismatches(); len(mismatches) > 0 {
+		return verification, fmt.Errorf("%d restored file(s) failed verification", len(mismatches))
+	}
+
+	return verification, nil
+}
+
+// escapePattern escapes the pattern metacharacters of a path, for use as a restic --include pattern.
+func escapePattern(path string) string {
+
+	re := regexp.MustCompile(`([*?\[\\])`)
+
+	return re.ReplaceAllString(path, `\$1`)
+}
+
+// AssertContents checks the content of a snapshot against the profile content
+// assertions (see ProfileConfiguration.ContentAssertions), logging each failed
+// assertion as an error and returning an error if any failed.
+func (restic *Restic) AssertContents(profile *ProfileConfiguration, snapshot string) error {
+
+	assertions := profile.ContentAssertions()
+	if len(assertions) == 0 || AppConfig.DryRun {
+		return nil
+	}
+
+	glog.Infof("Asserting content of snapshot %s", snapshot)
+
+	nodes, err := restic.Nodes(profile, snapshot)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, assertion := range assertions {
+		if err := assertion.Check(nodes); err != nil {
+			glog.Errorf("Content assertion failed: %v", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d content assertion(s) failed for snapshot %s", failed, len(assertions), snapshot)
+	}
+
+	glog.Infof("All %d content assertion(s) satisfied.", len(assertions))
+
+	return nil
+}
+
+// RepositoryID retrieves the ID of the repository, via "restic cat config".
+func (restic *Restic) RepositoryID(profile *ProfileConfiguration) (string, error) {
+
+	stdout, stderr, err := restic.execute("cat", []string{"config"}, profile)
+
+	if err != nil {
+		return "", fmt.Errorf("Could not retrieve repository config: %v: %s", err, stderr)
+	}
+
+	return parseRepositoryID(stdout)
+}
+
+// CheckRepositoryIdentity returns an error if the repository at the profile
+// repository location is not accepted (see RepositoryIdentity.Accept), or if
+// there is no repository (i.e., exists is false) but one has been recorded or
+// configured, e.g., because a different disk has been mounted there. The first
+// repository ID seen is recorded, as are accepted rotation members.
+func (restic *Restic) CheckRepositoryIdentity(profile *ProfileConfiguration, exists bool) error {
+
+	if AppConfig.DryRun {
+		return nil
+	}
+
+	identity, err := AppConfig.LoadRepositoryIdentity(profile.Name())
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if identity.Known(profile.RepositoryIDs()) {
+			return fmt.Errorf("Refusing to proceed: no repository exists at %v, but one is expected (e.g., a different disk is mounted there)", profile.Repository())
+		}
+		return nil
+	}
+
+	id, err := restic.RepositoryID(profile)
+	if err != nil {
+		return err
+	}
+
+	if err := identity.Accept(id, profile.RepositoryIDs()); err != nil {
+		return fmt.Errorf("Refusing to proceed with repository at %v: %v", profile.Repository(), err)
+	}
+
+	if err := AppConfig.SaveRepositoryIdentity(profile.Name(), identity); err != nil {
+		return fmt.Errorf("Could not save repository identity: %v", err)
+	}
+
+	glog.Infof("Repository at %v has accepted ID %s", profile.Repository(), id)
+	restic.repositoryID = id
+
+	return nil
+}
+
+// recordBackup records a backup to the (accepted) repository.
+func (restic *Restic) recordBackup(profile *ProfileConfiguration) error {
+
+	identity, err := AppConfig.LoadRepositoryIdentity(profile.Name())
+	if err != nil {
+		return err
+	}
+
+	identity.RecordBackup(restic.repositoryID, time.Now())
+
+	if err := AppConfig.SaveRepositoryIdentity(profile.Name(), identity); err != nil {
+		return fmt.Errorf("Could not save repository identity: %v", err)
+	}
+
+	return nil
+}
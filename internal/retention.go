@@ -0,0 +1,126 @@
+package resticmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// forgetGroup encapsulates a single snapshot group, as reported by "restic forget --json".
+type forgetGroup struct {
+	Tags    []string       `json:"tags"`
+	Host    string         `json:"host"`
+	Paths   []string       `json:"paths"`
+	Keep    []Snapshot     `json:"keep"`
+	Remove  []Snapshot     `json:"remove"`
+	Reasons []forgetReason `json:"reasons"`
+}
+
+// forgetReason encapsulates the retention rules that keep a snapshot.
+type forgetReason struct {
+	Snapshot Snapshot `json:"snapshot"`
+	Matches  []string `json:"matches"`
+}
+
+// RetentionDecision encapsulates the keep/remove decision for a single snapshot,
+// along with the rules (if any) that keep it.
+type RetentionDecision struct {
+	Snapshot Snapshot
+	Keep     bool
+	Reasons  []string
+}
+
+// RetentionPlan encapsulates the outcome of applying retention policies, as
+// previewed by "restic forget --dry-run --json", newest snapshot first.
+type RetentionPlan struct {
+	Decisions []RetentionDecision
+	// ExpiredPins holds the expired (and so not honoured) pins of the planned snapshots.
+	ExpiredPins []Pin
+}
+
+// ParseRetentionPlan parses the output of "restic forget --dry-run --json".
+func ParseRetentionPlan(output string) (*RetentionPlan, error) {
+
+	plan := &RetentionPlan{Decisions: make([]RetentionDecision, 0)}
+
+	// restic may print non-JSON text (e.g., warnings) before the JSON document
+	start := strings.Index(output, "[")
+	if strings.TrimSpace(output) == "" || start < 0 {
+		return plan, nil
+	}
+
+	var groups []forgetGroup
+	if err := json.Unmarshal([]byte(output[start:]), &groups); err != nil {
+		return nil, fmt.Errorf("Could not parse forget output: %v", err)
+	}
+
+	for _, group := range groups {
+
+		reasons := make(map[string][]string)
+		for _, reason := range group.Reasons {
+			reasons[reason.Snapshot.ID] = reason.Matches
+		}
+
+		for _, snapshot := range group.Keep {
+			plan.Decisions = append(plan.Decisions, RetentionDecision{Snapshot: snapshot, Keep: true, Reasons: reasons[snapshot.ID]})
+		}
+		for _, snapshot := range group.Remove {
+			plan.Decisions = append(plan.Decisions, RetentionDecision{Snapshot: snapshot, Keep: false})
+		}
+	}
+
+	sort.SliceStable(plan.Decisions, func(i, j int) bool {
+		return plan.Decisions[i].Snapshot.Time.After(plan.Decisions[j].Snapshot.Time)
+	})
+
+	return plan, nil
+}
+
+// Removed returns the snapshots that would be removed.
+func (plan *RetentionPlan) Removed() []Snapshot {
+
+	removed := make([]Snapshot, 0)
+	for _, decision := range plan.Decisions {
+		if !decision.Keep {
+			removed = append(removed, decision.Snapshot)
+		}
+	}
+
+	return removed
+}
+
+// RetentionGuard encapsulates limits on the snapshots that retention policy
+// application may remove, protecting against, e.g., a mistyped policy.
+type RetentionGuard struct {
+	// MaxRemoveFraction is the maximum fraction (0-1) of snapshots that may be removed.
+	MaxRemoveFraction float64
+	// ProtectNewerThan is the age below which no snapshot may be removed (if positive).
+	ProtectNewerThan time.Duration
+}
+
+// Check returns an error if the plan violates the guard limits, as of now.
+func (guard RetentionGuard) Check(plan *RetentionPlan, now time.Time) error {
+
+	removed := plan.Removed()
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if fraction := float64(len(removed)) / float64(len(plan.Decisions)); fraction > guard.MaxRemoveFraction {
+		return fmt.Errorf("%d of %d snapshots (%.0f%%) would be removed, more than the permitted %.0f%% (retention.max-remove-fraction)",
+			len(removed), len(plan.Decisions), 100*fraction, 100*guard.MaxRemoveFraction)
+	}
+
+	if guard.ProtectNewerThan > 0 {
+		for _, snapshot := range removed {
+			if age := now.Sub(snapshot.Time); age < guard.ProtectNewerThan {
+				return fmt.Errorf("snapshot %s (%s) would be removed, but is newer than %v (retention.protect-newer-than)",
+					snapshot.ShortID, snapshot.Time.Format("2006-01-02 15:04:05"), guard.ProtectNewerThan)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package resticmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestRetentionPlan(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	output := `[{"tags":null,"host":"nas","paths":["/home"],
+"keep":[
+ {"time":"2024-03-03T02:00:00Z","hostname":"nas","paths":["/home"],"id":"c3","short_id":"c3"},
+ {"time":"2024-03-01T02:00:00Z","hostname":"nas","paths":["/home"],"id":"a1","short_id":"a1"}],
+"remove":[
+ {"time":"2024-03-02T02:00:00Z","hostname":"nas","paths":["/home"],"id":"b2","short_id":"b2"}],
+"reasons":[
+ {"snapshot":{"id":"c3"},"matches":["daily snapshot","last snapshot"]},
+ {"snapshot":{"id":"a1"},"matches":["monthly snapshot"]}]}]
+`
+
+	plan, err := ParseRetentionPlan(output)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(plan.Decisions).Should(gomega.HaveLen(3))
+
+	// Newest first
+	g.Expect(plan.Decisions[0].Snapshot.ID).Should(gomega.Equal("c3"))
+	g.Expect(plan.Decisions[0].Keep).Should(gomega.BeTrue())
+	g.Expect(plan.Decisions[0].Reasons).Should(gomega.Equal([]string{"daily snapshot", "last snapshot"}))
+	g.Expect(plan.Decisions[1].Snapshot.ID).Should(gomega.Equal("b2"))
+	g.Expect(plan.Decisions[1].Keep).Should(gomega.BeFalse())
+	g.Expect(plan.Removed()).Should(gomega.HaveLen(1))
+
+	now := time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	g.Expect(RetentionGuard{MaxRemoveFraction: 0.5, ProtectNewerThan: 24 * time.Hour}.Check(plan, now)).Should(gomega.Succeed())
+	g.Expect(RetentionGuard{MaxRemoveFraction: 0.25}.Check(plan, now)).Should(gomega.MatchError(gomega.ContainSubstring("1 of 3 snapshots (33%)")))
+	g.Expect(RetentionGuard{MaxRemoveFraction: 1, ProtectNewerThan: 48 * time.Hour}.Check(plan, now)).Should(gomega.MatchError(gomega.ContainSubstring("snapshot b2")))
+
+	// Empty output (e.g., a dry-run) is an empty plan
+	plan, err = ParseRetentionPlan("")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(plan.Decisions).Should(gomega.BeEmpty())
+	g.Expect(RetentionGuard{}.Check(plan, now)).Should(gomega.Succeed())
+}
@@ -0,0 +1,59 @@
+package resticmanager
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// scaffoldIdentityKeys lists the profile keys written explicitly by ProfileScaffold,
+// and so excluded from its commented-out defaults.
+var scaffoldIdentityKeys = []string{
+	"name",
+	"password",
+	"source",
+	"repo",
+	"active",
+	"tags",
+}
+
+// ProfileScaffold returns the content of a new (inactive) profile file. The
+// application profile-defaults are included as comments, for reference.
+func ProfileScaffold(name string, source string, repo string, tags []string, defaults map[string]interface{}) []byte {
+
+	var buffer bytes.Buffer
+
+	fmt.Fprintf(&buffer, "# restic-manager profile %s.\n", name)
+	fmt.Fprintf(&buffer, "# The profile is inactive until enabled, e.g., restic-manager profile enable %s\n\n", name)
+
+	fmt.Fprintf(&buffer, "name: %s\n", formatScalar(name, 0))
+	fmt.Fprintf(&buffer, "password: \"\"\n")
+	fmt.Fprintf(&buffer, "source: %s\n", formatScalar(source, 0))
+	fmt.Fprintf(&buffer, "repo: %s\n", formatScalar(repo, 0))
+	fmt.Fprintf(&buffer, "active: false\n")
+
+	items := make([]string, len(tags))
+	for i, tag := range tags {
+		items[i] = formatScalar(tag, 0)
+	}
+	fmt.Fprintf(&buffer, "tags: [%s]\n", strings.Join(items, ", "))
+
+	inherited := redactSettings(defaults)
+	for _, key := range scaffoldIdentityKeys {
+		delete(inherited, key)
+	}
+
+	if len(inherited) > 0 {
+		content, err := yaml.Marshal(inherited)
+		if err == nil {
+			fmt.Fprintf(&buffer, "\n# Inherited from the application profile-defaults (uncomment to override):\n")
+			for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+				fmt.Fprintf(&buffer, "# %s\n", line)
+			}
+		}
+	}
+
+	return buffer.Bytes()
+}
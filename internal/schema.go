@@ -0,0 +1,888 @@
+package resticmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Schema value types (as per JSON Schema).
+const (
+	SchemaString  = "string"
+	SchemaBoolean = "boolean"
+	SchemaInteger = "integer"
+	SchemaNumber  = "number"
+	SchemaArray   = "array"
+	SchemaObject  = "object"
+)
+
+// Schema value formats, providing validation beyond the value type.
+const (
+	// FormatDuration is a Go duration string, e.g., "5m" or "72h".
+	FormatDuration = "duration"
+	// FormatSize is a size in bytes, optionally with a (1024-based) unit
+	// suffix, e.g., "1mb", "50G" or "1.5T" (see sizeInBytes).
+	FormatSize = "size"
+	// FormatRetentionValue is a retention policy value: a count, a restic
+	// duration (e.g., "2y5m7d") or a tag, depending on the policy period.
+	FormatRetentionValue = "retention-value"
+)
+
+// SchemaNode describes the permitted structure of a configuration value.
+type SchemaNode struct {
+	Type        string
+	Description string
+	Format      string
+	Enum        []string
+	Deprecated  string
+
+	// Properties describes the known keys of an object.
+	Properties map[string]*SchemaNode
+	// AdditionalProperties describes the values of any other keys of an object;
+	// if nil, other keys are not permitted.
+	AdditionalProperties *SchemaNode
+	// PropertyNames lists the permitted keys of an object with AdditionalProperties.
+	PropertyNames []string
+	// PropertyNamesDescription describes the permitted keys, e.g., "log level".
+	PropertyNamesDescription string
+
+	// Items describes the items of an array.
+	Items *SchemaNode
+
+	// Mergeable indicates that keys at or beneath this node may carry merge directives (see mergeSettings).
+	Mergeable bool
+}
+
+// ValidationIssue describes a single configuration problem.
+type ValidationIssue struct {
+	File    string
+	Line    int
+	Column  int
+	Key     string
+	Message string
+}
+
+// String returns a compiler-style representation of the issue, e.g., "file.yml:12:3: keep-polcy: unknown key".
+func (issue ValidationIssue) String() string {
+
+	location := issue.File
+	if issue.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", issue.File, issue.Line, issue.Column)
+	}
+
+	if issue.Key == "" {
+		return fmt.Sprintf("%s: %s", location, issue.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", location, issue.Key, issue.Message)
+}
+
+func schemaString(description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaString, Description: description}
+}
+
+func schemaBoolean(description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaBoolean, Description: description}
+}
+
+func schemaInteger(description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaInteger, Description: description}
+}
+
+func schemaNumber(description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaNumber, Description: description}
+}
+
+func schemaEnum(description string, values ...string) *SchemaNode {
+	return &SchemaNode{Type: SchemaString, Description: description, Enum: values}
+}
+
+func schemaFormat(format string, description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaString, Format: format, Description: description}
+}
+
+func schemaStringList(description string) *SchemaNode {
+	return &SchemaNode{Type: SchemaArray, Description: description, Items: &SchemaNode{Type: SchemaString}}
+}
+
+func schemaObject(description string, properties map[string]*SchemaNode) *SchemaNode {
+	return &SchemaNode{Type: SchemaObject, Description: description, Properties: properties}
+}
+
+// logLevelNames returns the names of all log levels.
+func logLevelNames() []string {
+
+	names := make([]string, 0)
+	for _, level := range glog.ListLogLevels() {
+		names = append(names, level.String())
+	}
+
+	return names
+}
+
+func schemaLogLevel(description string) *SchemaNode {
+	return schemaEnum(description, logLevelNames()...)
+}
+
+func schemaThresholds(description string) *SchemaNode {
+
+	return &SchemaNode{
+		Type:        SchemaArray,
+		Description: description,
+		Items: &SchemaNode{
+			Type:                     SchemaObject,
+			AdditionalProperties:     schemaInteger("Minimum number of messages at this level"),
+			PropertyNames:            logLevelNames(),
+			PropertyNamesDescription: "log level",
+		},
+	}
+}
+
+// emailTemplateProperties adds the email template keys to a set of properties.
+func emailTemplateProperties(properties map[string]*SchemaNode) map[string]*SchemaNode {
+
+	properties["template"] = schemaString("Inline html email template (layered on top of the default)")
+	properties["template-file"] = schemaString("Html email template file (layered on top of the default)")
+	properties["text-template"] = schemaString("Inline plain-text email template")
+	properties["text-template-file"] = schemaString("Plain-text email template file")
+
+	return properties
+}
+
+// RetentionCountPeriods lists the retention policy periods that keep a number of snapshots.
+var RetentionCountPeriods = []string{"last", "hourly", "daily", "weekly", "monthly", "yearly"}
+
+// RetentionWithinPeriods lists the retention policy periods that keep snapshots within a duration.
+var RetentionWithinPeriods = []string{"within", "within-hourly", "within-daily", "within-weekly", "within-monthly", "within-yearly"}
+
+// RetentionTagPeriod is the retention policy period that keeps snapshots with a tag.
+const RetentionTagPeriod = "tag"
+
+// RetentionPeriods lists the valid retention policy periods.
+var RetentionPeriods = append(append(append([]string{}, RetentionCountPeriods...), RetentionWithinPeriods...), RetentionTagPeriod)
+
+// RetentionScopes lists the snapshot properties by which retention policy application may be scoped.
+var RetentionScopes = []string{"host", "paths", "tags"}
+
+// ProfileSchema returns the schema of a profile configuration (and of the application profile-defaults).
+func ProfileSchema() *SchemaNode {
+
+	schema := schemaObject("restic-manager profile", map[string]*SchemaNode{
+
+		"include": schemaStringList("Profile fragments, merged (in order) before this profile's own keys"),
+
+		"matrix": schemaObject("Generate one profile per matrix value (from exactly one of values, glob or command)", map[string]*SchemaNode{
+			"values":  schemaStringList("Matrix values"),
+			"glob":    schemaString("Pattern of directories, each of which is a matrix value"),
+			"command": schemaString("Shell command, each line of the output of which is a matrix value"),
+			"name":    schemaString("Template of generated profile names (default: {{.name}}-{{.matrix.base}})"),
+		}),
+
+		"name":     schemaString("Profile name"),
+		"password": schemaString("Repository password"),
+		"source":   schemaString("Backup source path"),
+		"repo":     schemaString("Backup repository path"),
+		"active":   schemaBoolean("Only active profiles are processed"),
+		"tags":     schemaStringList("Profile tags, used for profile selection"),
+		"schedule": schemaString("Informational schedule description, e.g., \"daily 02:00\" (not acted upon)"),
+
+		"hostname":      schemaString("Hostname recorded in snapshots, and by which retention policy application is scoped (default: the local hostname)"),
+		"snapshot-tags": schemaStringList("Tags applied to snapshots, and by which retention policy application is scoped"),
+
+		"hosts":         schemaStringList("Hostname (glob) patterns of the hosts for which the profile is intended (default: all)"),
+		"exclude-hosts": schemaStringList("Hostname (glob) patterns of the hosts for which the profile is not intended"),
+
+		"operation-sequence": {
+			Type:        SchemaArray,
+			Description: "Operations performed by the auto command, in order",
+			Items:       schemaEnum("", Operations...),
+		},
+
+		"keep-policy": {
+			Type:        SchemaArray,
+			Description: "Retention policy",
+			Items: schemaObject("", map[string]*SchemaNode{
+				"period": schemaEnum("Retention period", RetentionPeriods...),
+				"value":  schemaFormat(FormatRetentionValue, "Number of snapshots (e.g., 7), duration (within periods; e.g., 2y5m7d3h) or tag (tag period) to keep"),
+			}),
+		},
+
+		"retention": schemaObject("Retention policy application options", map[string]*SchemaNode{
+			"group-by": schemaString("Snapshot grouping, e.g., \"host,paths\" (default: restic's default)"),
+			"scope": {
+				Type:        SchemaArray,
+				Description: "Snapshot properties (of this profile) to which retention policy application is restricted (default: all; empty: the whole repository)",
+				Items:       schemaEnum("", RetentionScopes...),
+			},
+			"max-remove-fraction": schemaNumber("Maximum fraction (0-1) of snapshots that may be removed at once, else retention policy application is refused (default: 0.5)"),
+			"protect-newer-than":  schemaFormat(FormatDuration, "Retention policy application is refused if it would remove a snapshot newer than this (default: 24h; 0 disables)"),
+		}),
+
+		"repository-identity": schemaObject("Repository identity pinning; the first repository ID seen is recorded, and any other refused", map[string]*SchemaNode{
+			"ids":           schemaStringList("Additional accepted repository IDs (from \"restic cat config\"), e.g., of rotating disks"),
+			"overdue-after": schemaFormat(FormatDuration, "Time since its last backup after which a repository is reported overdue by status (default: 168h; 0 disables)"),
+		}),
+
+		"preflight": schemaObject("Requirements on the source, checked before each backup; the backup is skipped if any is not met", map[string]*SchemaNode{
+			"mount-point":      schemaBoolean("The source must be a mount point"),
+			"filesystem-uuid":  schemaString("The source must be on the filesystem with this UUID"),
+			"filesystem-label": schemaString("The source must be on the filesystem with this label"),
+			"sentinel":         schemaString("This file (relative to the source, unless absolute) must exist"),
+			"min-entries":      schemaInteger("The source directory must have at least this many entries"),
+		}),
+
+		"must-contain": {
+			Type:        SchemaArray,
+			Description: "Requirements on the content of each snapshot, asserted after each backup",
+			Items: schemaObject("", map[string]*SchemaNode{
+				"path":      schemaString("Path or glob pattern (relative to the source, unless absolute); files at or beneath matching paths count"),
+				"min-size":  schemaFormat(FormatSize, "Minimum total size of the matching files"),
+				"min-files": schemaInteger("Minimum number of matching files"),
+			}),
+		},
+
+		"check": schemaObject("Check operation options", map[string]*SchemaNode{
+			"mode":    schemaEnum("metadata (structure only), subset (additionally read one part of the pack data per run, rotating) or budget (read parts until the budget is exhausted) (default: metadata)", CheckModes...),
+			"subsets": schemaInteger("Number of parts into which pack data is divided, so that it is all read over this many runs (default: 10)"),
+			"budget":  schemaFormat(FormatDuration, "Time budget for reading pack data, in budget mode (default: 1h)"),
+		}),
+
+		"verify-restore": schemaObject("Restore drill (verify-restore operation) options", map[string]*SchemaNode{
+			"sample": schemaInteger("Number of randomly-sampled files restored and verified (default: 10)"),
+			"paths":  schemaStringList("Critical paths (relative to the source, unless absolute), all files beneath which are always restored and verified"),
+		}),
+
+		"prune": schemaObject("Prune operation options", map[string]*SchemaNode{
+			"when":                  schemaEnum("When the prune operation prunes: always, or only when needed, i.e., if snapshots were forgotten or unused space exceeds unused-threshold (default: needed)", PruneModes...),
+			"unused-threshold":      schemaString("Unused space above which the repository is pruned, as a percentage (e.g., 10%) or size (e.g., 50G) (default: 10%)"),
+			"max-unused":            schemaString("Unused space tolerated after pruning, as a percentage, size or \"unlimited\" (restic --max-unused)"),
+			"max-repack-size":       schemaString("Maximum amount of data repacked, e.g., 50G (restic --max-repack-size)"),
+			"repack-cacheable-only": schemaBoolean("Repack only cacheable (i.e., tree) packs (restic --repack-cacheable-only)"),
+		}),
+
+		"exclusions": schemaStringList("Backup exclusions (template expansion is supported)"),
+
+		"change-thresholds": schemaObject("Snapshot diff change thresholds", map[string]*SchemaNode{
+			"totalfiles": schemaInteger("Changed file count threshold"),
+			"totalbytes": schemaNumber("Changed byte count threshold"),
+		}),
+
+		"anomaly-detection": schemaObject("Snapshot diff anomaly detection, relative to a rolling baseline of recent runs", map[string]*SchemaNode{
+			"enabled":                   schemaBoolean("Detect anomalies (default: true)"),
+			"history":                   schemaInteger("Number of recent runs forming the baseline (default: 20)"),
+			"min-history":               schemaInteger("Number of runs required before outliers are reported (default: 5)"),
+			"sigma":                     schemaNumber("Standard deviations above the baseline mean beyond which files changed, files removed or bytes added is an outlier (default: 3)"),
+			"file-drop-fraction":        schemaNumber("Fraction (0-1) of files which, if lost since the previous run, is reported (default: 0.1)"),
+			"extension-change-fraction": schemaNumber("Fraction (0-1) of changed and removed files replaced by files with a different extension that is reported (default: 0.5)"),
+			"extension-change-min":      schemaInteger("Minimum number of files replaced by files with a different extension that is reported (default: 20)"),
+			"entropy-sample":            schemaInteger("Number of changed files sampled for content entropy (default: 0, i.e., no sampling)"),
+			"entropy-jump":              schemaNumber("Rise in mean sampled content entropy (bits per byte) above the baseline that is reported (default: 1)"),
+		}),
+
+		"arguments": {
+			Type:                 SchemaObject,
+			Description:          "Extra restic arguments, per restic command",
+			AdditionalProperties: schemaStringList(""),
+		},
+
+		"logging": schemaObject("Profile logging options", map[string]*SchemaNode{
+			"file":   schemaString("Profile log file (template expansion is supported)"),
+			"level":  schemaLogLevel("Profile log file level"),
+			"append": schemaBoolean("Append to (rather than replace) the profile log file"),
+		}),
+
+		"email": schemaObject("Profile email options", emailTemplateProperties(map[string]*SchemaNode{
+			"level":          schemaLogLevel("Minimum level of included log messages"),
+			"recipients":     schemaStringList("Profile email recipients"),
+			"thresholds":     schemaThresholds("Email is sent only if a threshold is reached"),
+			"attach-log":     schemaBoolean("Attach the profile log file"),
+			"attach-output":  schemaBoolean("Attach the raw restic output"),
+			"compress-above": schemaFormat(FormatSize, "Attachments larger than this are compressed"),
+		})),
+	})
+
+	schema.Mergeable = true
+
+	return schema
+}
+
+// AppSchema returns the schema of the application configuration.
+func AppSchema() *SchemaNode {
+
+	schema := schemaObject("restic-manager application configuration", map[string]*SchemaNode{
+
+		"executable": schemaString("Path to the restic executable"),
+		"tempdir":    schemaString("Location for restic's temporary files"),
+		"state-dir":  schemaString("Location for persistent application state"),
+
+		"logging": schemaObject("Application logging options", map[string]*SchemaNode{
+			"file":   schemaString("Application log file"),
+			"level":  schemaLogLevel("Application log file level"),
+			"append": schemaBoolean("Append to (rather than replace) the application log file"),
+			"raw":    schemaString("Raw (low-level) log file"),
+		}),
+
+		"email": schemaObject("Application email options", emailTemplateProperties(map[string]*SchemaNode{
+			"sender":     schemaString("Email sender address"),
+			"recipients": schemaStringList("Email recipients"),
+			"level":      schemaLogLevel("Minimum level of included log messages"),
+			"thresholds": schemaThresholds("Email is sent only if a threshold is reached"),
+			"smtp": schemaObject("SMTP server", map[string]*SchemaNode{
+				"host":     schemaString("SMTP host"),
+				"port":     schemaInteger("SMTP port"),
+				"username": schemaString("SMTP username"),
+				"password": schemaString("SMTP password"),
+				"tls":      schemaBoolean("Use TLS"),
+			}),
+		})),
+
+		"notify": schemaObject("Outbound notification spool", map[string]*SchemaNode{
+			"spool-dir":     schemaString("Spool location"),
+			"max-age":       schemaFormat(FormatDuration, "Undelivered notifications older than this are discarded"),
+			"retry-backoff": schemaFormat(FormatDuration, "Initial delay between delivery attempts"),
+			"max-backoff":   schemaFormat(FormatDuration, "Maximum delay between delivery attempts"),
+		}),
+
+		"profile-defaults": ProfileSchema(),
+	})
+
+	// Each environment is itself (an overlay of) an application configuration
+	schema.Properties[environmentsKey] = &SchemaNode{
+		Type:                 SchemaObject,
+		Description:          "Named environments, one of which may be overlaid on the configuration (see --env)",
+		AdditionalProperties: schema,
+	}
+
+	return schema
+}
+
+// JSONSchema returns a JSON Schema (draft-07) representation of the schema.
+func (schema *SchemaNode) JSONSchema() ([]byte, error) {
+
+	document := schema.jsonSchema(false, schema)
+	document["$schema"] = "http://json-schema.org/draft-07/schema#"
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// jsonSchema returns the JSON Schema representation of a node within the
+// schema with the specified root; references to the root (e.g., from within
+// the application environments) are represented as such.
+func (schema *SchemaNode) jsonSchema(mergeable bool, root *SchemaNode) map[string]interface{} {
+
+	mergeable = mergeable || schema.Mergeable
+
+	document := map[string]interface{}{}
+
+	if schema.Description != "" {
+		document["description"] = schema.Description
+	}
+	if schema.Deprecated != "" {
+		document["deprecated"] = true
+		document["description"] = strings.TrimSpace(schema.Description + " (deprecated: " + schema.Deprecated + ")")
+	}
+
+	switch schema.Format {
+	case FormatDuration:
+		// Durations may also be given as an integer number of nanoseconds.
+		document["type"] = []string{SchemaString, SchemaInteger}
+		document["pattern"] = durationPattern
+	case FormatSize:
+		document["type"] = []string{SchemaString, SchemaInteger}
+		document["pattern"] = sizePattern
+	case FormatRetentionValue:
+		document["type"] = []string{SchemaString, SchemaInteger}
+	default:
+		document["type"] = schema.Type
+	}
+
+	if len(schema.Enum) > 0 {
+		document["enum"] = schema.Enum
+	}
+
+	if schema.Items != nil {
+		document["items"] = schema.Items.jsonSchema(mergeable, root)
+	}
+
+	if schema.Type == SchemaObject {
+
+		properties := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			properties[name] = property.jsonSchema(mergeable, root)
+			if mergeable && property.Type == SchemaArray {
+				properties[name+mergeAppendSuffix] = property.jsonSchema(mergeable, root)
+			}
+			if mergeable && (property.Type == SchemaArray || property.Type == SchemaObject) {
+				properties[name+mergeReplaceSuffix] = property.jsonSchema(mergeable, root)
+			}
+		}
+		if len(properties) > 0 {
+			document["properties"] = properties
+		}
+
+		if schema.AdditionalProperties == root {
+			document["additionalProperties"] = map[string]interface{}{"$ref": "#"}
+		} else if schema.AdditionalProperties != nil {
+			document["additionalProperties"] = schema.AdditionalProperties.jsonSchema(mergeable, root)
+		} else {
+			document["additionalProperties"] = false
+		}
+
+		if len(schema.PropertyNames) > 0 {
+			document["propertyNames"] = map[string]interface{}{"enum": schema.PropertyNames}
+		}
+	}
+
+	return document
+}
+
+const (
+	durationPattern = `^([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$`
+	sizePattern     = `^\s*[0-9]+(\.[0-9]+)?\s*([kKmMgGtT]?[bB]?)\s*$`
+	// resticDurationPattern matches a restic (forget) duration, e.g., "2y5m7d3h".
+	resticDurationPattern = `^([0-9]+[ymdh])+$`
+)
+
+var resticDurationRegexp = regexp.MustCompile(resticDurationPattern)
+
+var sizeRegexp = regexp.MustCompile(sizePattern)
+
+// sizeInBytes parses a size, optionally with a (1024-based, case-insensitive)
+// unit suffix K, M, G or T, optionally followed by B. Both the viper style
+// (e.g., "512kb" or "1g") and the restic style (e.g., "50G" or "1.5T") are
+// accepted, so that all size settings share a single syntax.
+func sizeInBytes(text string) (int64, error) {
+
+	text = strings.ToLower(strings.TrimSpace(text))
+	if !sizeRegexp.MatchString(text) {
+		return 0, fmt.Errorf("invalid size %q (e.g., 512kb, 50G or 1.5T)", text)
+	}
+
+	text = strings.TrimSuffix(text, "b")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(text, "k"):
+		multiplier = 1024
+	case strings.HasSuffix(text, "m"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(text, "g"):
+		multiplier = 1024 * 1024 * 1024
+	case strings.HasSuffix(text, "t"):
+		multiplier = 1024 * 1024 * 1024 * 1024
+	}
+	text = strings.TrimSpace(strings.TrimRight(text, "kmgt"))
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q (e.g., 512kb, 50G or 1.5T)", text)
+	}
+
+	return int64(math.Round(value * multiplier)), nil
+}
+
+var yaml11Booleans = []string{"y", "yes", "n", "no", "on", "off"}
+
+// ValidateSettingsFile validates a configuration file against a schema,
+// returning a list of issues (with file and line, where available).
+func ValidateSettingsFile(filename string, schema *SchemaNode) ([]ValidationIssue, error) {
+
+	root, err := readSettingsNode(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]ValidationIssue, 0)
+	if root != nil {
+		issues = schema.validate(root, filename, "", false, issues)
+	}
+
+	return issues, nil
+}
+
+// readSettingsNode reads a configuration file into a yaml node tree. YAML (and
+// JSON) files retain line numbers; other formats (as supported by viper) are
+// converted and have no line information.
+func readSettingsNode(filename string) (*yamlv3.Node, error) {
+
+	var document yamlv3.Node
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+
+	case ".yaml", ".yml", ".json":
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := yamlv3.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("Could not parse %v: %v", filename, err)
+		}
+
+	default:
+		settings, err := readSettings(filename)
+		if err != nil {
+			return nil, err
+		}
+		return settingsNode(settings)
+	}
+
+	if len(document.Content) == 0 {
+		// Empty document
+		return nil, nil
+	}
+
+	return document.Content[0], nil
+}
+
+// settingsNode converts settings into a yaml node tree (without line information).
+func settingsNode(settings map[string]interface{}) (*yamlv3.Node, error) {
+
+	var document yamlv3.Node
+
+	content, err := yamlv3.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	clearNodeLines(&document)
+
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	return document.Content[0], nil
+}
+
+// ValidateSettings validates settings (e.g., command-line overrides) against a
+// schema, returning a list of issues attributed to origin.
+func (schema *SchemaNode) ValidateSettings(settings map[string]interface{}, origin string) []ValidationIssue {
+
+	issues := make([]ValidationIssue, 0)
+
+	root, err := settingsNode(settings)
+	if err != nil {
+		return append(issues, ValidationIssue{File: origin, Message: err.Error()})
+	}
+	if root != nil {
+		issues = schema.validate(root, origin, "", false, issues)
+	}
+
+	return issues
+}
+
+func clearNodeLines(node *yamlv3.Node) {
+
+	node.Line = 0
+	node.Column = 0
+	for _, child := range node.Content {
+		clearNodeLines(child)
+	}
+}
+
+func newIssue(filename string, node *yamlv3.Node, key string, format string, args ...interface{}) ValidationIssue {
+
+	return ValidationIssue{
+		File:    filename,
+		Line:    node.Line,
+		Column:  node.Column,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// nodeTypeName returns a descriptive name of the type of a yaml node.
+func nodeTypeName(node *yamlv3.Node) string {
+
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		return "map"
+	case yamlv3.SequenceNode:
+		return "list"
+	case yamlv3.ScalarNode:
+		switch node.ShortTag() {
+		case "!!str":
+			return "string"
+		case "!!bool":
+			return "boolean"
+		case "!!int":
+			return "integer"
+		case "!!float":
+			return "number"
+		case "!!null":
+			return "null"
+		}
+	}
+
+	return "value"
+}
+
+// typeName returns a descriptive name of a schema type.
+func typeName(schemaType string) string {
+
+	switch schemaType {
+	case SchemaArray:
+		return "list"
+	case SchemaObject:
+		return "map"
+	}
+
+	return schemaType
+}
+
+// validate validates a yaml node against the schema, appending any issues.
+func (schema *SchemaNode) validate(node *yamlv3.Node, filename string, key string, mergeable bool, issues []ValidationIssue) []ValidationIssue {
+
+	mergeable = mergeable || schema.Mergeable
+
+	for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	// An empty (null) value is equivalent to an unset value
+	if node.Kind == yamlv3.ScalarNode && node.ShortTag() == "!!null" {
+		return issues
+	}
+
+	if schema.Deprecated != "" {
+		issues = append(issues, newIssue(filename, node, key, "deprecated: %s", schema.Deprecated))
+	}
+
+	switch schema.Type {
+
+	case SchemaObject:
+
+		if node.Kind != yamlv3.MappingNode {
+			return append(issues, newIssue(filename, node, key, "expected a %s, found a %s", typeName(schema.Type), nodeTypeName(node)))
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			issues = schema.validateProperty(node.Content[i], node.Content[i+1], filename, key, mergeable, issues)
+		}
+
+	case SchemaArray:
+
+		if node.Kind != yamlv3.SequenceNode {
+			return append(issues, newIssue(filename, node, key, "expected a %s, found a %s", typeName(schema.Type), nodeTypeName(node)))
+		}
+
+		if schema.Items != nil {
+			for i, item := range node.Content {
+				issues = schema.Items.validate(item, filename, fmt.Sprintf("%s[%d]", key, i), mergeable, issues)
+			}
+		}
+
+	default:
+
+		if node.Kind != yamlv3.ScalarNode {
+			return append(issues, newIssue(filename, node, key, "expected a %s, found a %s", typeName(schema.Type), nodeTypeName(node)))
+		}
+
+		issues = schema.validateScalar(node, filename, key, issues)
+	}
+
+	return issues
+}
+
+// validateProperty validates a single key/value pair of a map.
+func (schema *SchemaNode) validateProperty(keyNode *yamlv3.Node, valueNode *yamlv3.Node, filename string, parent string, mergeable bool, issues []ValidationIssue) []ValidationIssue {
+
+	// Keys are case-insensitive (as per viper)
+	name := strings.ToLower(keyNode.Value)
+	fullKey := joinKey(parent, keyNode.Value)
+
+	directive := ""
+	if mergeable {
+		for _, suffix := range []string{mergeAppendSuffix, mergeReplaceSuffix} {
+			if strings.HasSuffix(name, suffix) {
+				directive = suffix
+				name = strings.TrimSuffix(name, suffix)
+				break
+			}
+		}
+	}
+
+	property, known := schema.Properties[name]
+
+	if !known {
+
+		if schema.AdditionalProperties == nil {
+			return append(issues, newIssue(filename, keyNode, fullKey, "unknown key%s", suggestKey(name, schema.Properties)))
+		}
+
+		if len(schema.PropertyNames) > 0 && !containsString(schema.PropertyNames, name) {
+			return append(issues, newIssue(filename, keyNode, fullKey, "invalid %s (expected one of %s)", schema.PropertyNamesDescription, strings.Join(schema.PropertyNames, ", ")))
+		}
+
+		property = schema.AdditionalProperties
+	}
+
+	if directive == mergeAppendSuffix && property.Type != SchemaArray {
+		issues = append(issues, newIssue(filename, keyNode, fullKey, "the %q (append) directive applies only to lists", mergeAppendSuffix))
+	}
+
+	return property.validate(valueNode, filename, fullKey, mergeable, issues)
+}
+
+// validateScalar validates a scalar yaml node against the schema.
+func (schema *SchemaNode) validateScalar(node *yamlv3.Node, filename string, key string, issues []ValidationIssue) []ValidationIssue {
+
+	tag := node.ShortTag()
+
+	switch schema.Format {
+
+	case FormatDuration:
+		if tag == "!!int" {
+			return issues
+		}
+		if _, err := time.ParseDuration(node.Value); err != nil {
+			return append(issues, newIssue(filename, node, key, "invalid duration %q (e.g., 30m or 72h)", node.Value))
+		}
+		return issues
+
+	case FormatSize:
+		if tag == "!!int" {
+			return issues
+		}
+		if !sizeRegexp.MatchString(node.Value) {
+			return append(issues, newIssue(filename, node, key, "invalid size %q (e.g., 512kb, 50G or 1.5T)", node.Value))
+		}
+		return issues
+
+	case FormatRetentionValue:
+		// Validated against the period when the profile is loaded (see RetentionPolicy.Validate)
+		return issues
+	}
+
+	switch schema.Type {
+
+	case SchemaBoolean:
+		// YAML 1.1 booleans (e.g., "yes") are accepted by viper
+		if tag != "!!bool" && !containsString(yaml11Booleans, strings.ToLower(node.Value)) {
+			return append(issues, newIssue(filename, node, key, "expected a boolean, found %q", node.Value))
+		}
+
+	case SchemaInteger:
+		if tag != "!!int" {
+			if _, err := strconv.Atoi(node.Value); err != nil {
+				return append(issues, newIssue(filename, node, key, "expected an integer, found %q", node.Value))
+			}
+		}
+
+	case SchemaNumber:
+		if tag != "!!int" && tag != "!!float" {
+			if _, err := strconv.ParseFloat(node.Value, 64); err != nil {
+				return append(issues, newIssue(filename, node, key, "expected a number, found %q", node.Value))
+			}
+		}
+
+	case SchemaString:
+		// Any scalar is acceptable as a string
+	}
+
+	if len(schema.Enum) > 0 && !containsString(schema.Enum, node.Value) {
+		return append(issues, newIssue(filename, node, key, "invalid value %q (expected one of %s)", node.Value, strings.Join(schema.Enum, ", ")))
+	}
+
+	return issues
+}
+
+// suggestKey returns a "did you mean" suggestion for an unknown key, if a similar known key exists.
+func suggestKey(name string, properties map[string]*SchemaNode) string {
+
+	best := ""
+	bestDistance := 3
+
+	names := make([]string, 0, len(properties))
+	for k := range properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		if d := editDistance(name, k); d < bestDistance {
+			best = k
+			bestDistance = d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func containsString(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateProfileFile validates a profile file, and any fragments it includes, against the profile schema.
+func ValidateProfileFile(filename string) ([]ValidationIssue, error) {
+
+	issues := make([]ValidationIssue, 0)
+	var validateErr error
+
+	visit := func(file string) {
+		fileIssues, err := ValidateSettingsFile(file, ProfileSchema())
+		if err != nil && validateErr == nil {
+			validateErr = err
+		}
+		issues = append(issues, fileIssues...)
+	}
+
+	if _, err := mergeProfileFile(make(map[string]interface{}), filename, nil, nil, visit); err != nil {
+		return issues, err
+	}
+
+	return issues, validateErr
+}
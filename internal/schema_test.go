@@ -0,0 +1,89 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestValidateSettingsFile(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "schema")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	content := `name: test
+keep-polcy: []
+keep-policy:
+  - period: fortnightly
+    value: 7
+active: yes
+operation-sequence: [backup, bakup]
+logging:
+  level: verbose
+email:
+  thresholds:
+    - warn: 1
+exclusions+: ['*.tmp']
+notes+: []
+`
+	filename := filepath.Join(directory, "profile.yml")
+	g.Expect(ioutil.WriteFile(filename, []byte(content), 0600)).Should(gomega.Succeed())
+
+	issues, err := ValidateSettingsFile(filename, ProfileSchema())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	g.Expect(keys).Should(gomega.Equal([]string{
+		"keep-polcy",
+		"keep-policy[0].period",
+		"operation-sequence[1]",
+		"logging.level",
+		"email.thresholds[0].warn",
+		"notes+",
+	}))
+
+	g.Expect(issues[0].Line).Should(gomega.Equal(2))
+	g.Expect(issues[0].String()).Should(gomega.ContainSubstring(`did you mean "keep-policy"?`))
+	g.Expect(issues[2].Line).Should(gomega.Equal(7))
+	g.Expect(issues[2].Column).Should(gomega.Equal(30))
+
+	// Merge directives are not permitted in the application configuration (outside profile-defaults)
+	appContent := "executable+: restic\nnotify:\n  max-age: 3 days\nprofile-defaults:\n  tags+: [a]\n"
+	appFilename := filepath.Join(directory, "app.yml")
+	g.Expect(ioutil.WriteFile(appFilename, []byte(appContent), 0600)).Should(gomega.Succeed())
+
+	issues, err = ValidateSettingsFile(appFilename, AppSchema())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(issues).Should(gomega.HaveLen(2))
+	g.Expect(issues[0].Key).Should(gomega.Equal("executable+"))
+	g.Expect(issues[1].Key).Should(gomega.Equal("notify.max-age"))
+}
+
+func TestPublishedSchema(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	// The published schema files must match the schema (regenerate them with
+	// "restic-manager config schema {app|profile}" if this fails).
+	for name, schema := range map[string]*SchemaNode{
+		"app":     AppSchema(),
+		"profile": ProfileSchema(),
+	} {
+		expected, err := schema.JSONSchema()
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+		published, err := ioutil.ReadFile(filepath.Join("..", "schema", name+".schema.json"))
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+		g.Expect(string(published)).Should(gomega.Equal(string(expected)+"\n"), name)
+	}
+}
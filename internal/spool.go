@@ -0,0 +1,322 @@
+package resticmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/i-am-david-fernandez/glog"
+)
+
+// SpoolItem encapsulates a single spooled (i.e., not yet delivered) notification.
+type SpoolItem struct {
+	ID          string
+	Created     time.Time
+	Attempts    int
+	LastAttempt time.Time
+	NextAttempt time.Time
+	LastError   string
+
+	Sender      string
+	Recipients  []string
+	Subject     string
+	Content     string
+	TextContent string
+	Attachments []MailAttachment
+}
+
+// Message returns a MailMessage reconstructed from the spooled item.
+func (item *SpoolItem) Message() *MailMessage {
+
+	message := NewMailMessage()
+	message.Sender = item.Sender
+	message.AddRecipients(item.Recipients...)
+	message.Subject = item.Subject
+	message.AddContent(item.Content)
+	message.AddTextContent(item.TextContent)
+	message.Attachments = append(message.Attachments, item.Attachments...)
+
+	return message
+}
+
+// SpoolFlushResult summarises the outcome of a spool flush.
+type SpoolFlushResult struct {
+	Sent     int
+	Failed   int
+	Deferred int
+	Expired  int
+}
+
+// Spool provides a persistent, on-disk queue of outbound notifications.
+// Every notification is written to the spool before delivery is attempted and
+// is only removed once delivered (or expired), so that a transient mail server
+// outage does not lose a report.
+type Spool struct {
+	directory  string
+	maxAge     time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// NewSpool creates and returns a new Spool rooted at the specified directory.
+func NewSpool(directory string, maxAge time.Duration, backoff time.Duration, maxBackoff time.Duration) *Spool {
+
+	return &Spool{
+		directory:  directory,
+		maxAge:     maxAge,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+	}
+}
+
+// Directory returns the spool directory.
+func (spool *Spool) Directory() string {
+	return spool.directory
+}
+
+func (spool *Spool) itemFile(id string) string {
+	return filepath.Join(spool.directory, id+".json")
+}
+
+// Add writes a message to the spool, returning the new spool item.
+func (spool *Spool) Add(message *MailMessage) (*SpoolItem, error) {
+
+	now := time.Now()
+
+	item := &SpoolItem{
+		ID:          fmt.Sprintf("%s-%d", now.Format("20060102T150405.000000000"), os.Getpid()),
+		Created:     now,
+		NextAttempt: now,
+		Sender:      message.Sender,
+		Recipients:  message.Recipients,
+		Subject:     RedactString(message.Subject),
+		Content:     message.Content(),
+		TextContent: message.TextContent(),
+		Attachments: message.Attachments,
+	}
+
+	if err := spool.save(item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// save (atomically) writes an item to the spool.
+func (spool *Spool) save(item *SpoolItem) error {
+
+	if err := os.MkdirAll(spool.directory, 0700); err != nil {
+		return fmt.Errorf("Could not create spool directory %s: %v", spool.directory, err)
+	}
+
+	content, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode spool item %s: %v", item.ID, err)
+	}
+
+	filename := spool.itemFile(item.ID)
+	if err := ioutil.WriteFile(filename+".tmp", content, 0600); err != nil {
+		return fmt.Errorf("Could not write spool item %s: %v", filename, err)
+	}
+
+	return os.Rename(filename+".tmp", filename)
+}
+
+// remove deletes an item from the spool.
+func (spool *Spool) remove(item *SpoolItem) error {
+	return os.Remove(spool.itemFile(item.ID))
+}
+
+// Items returns all items currently in the spool, oldest first.
+func (spool *Spool) Items() ([]*SpoolItem, error) {
+
+	items := make([]*SpoolItem, 0)
+
+	files, err := ioutil.ReadDir(spool.directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return items, nil
+		}
+		return items, fmt.Errorf("Could not read spool directory %s: %v", spool.directory, err)
+	}
+
+	for _, file := range files {
+
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		filename := filepath.Join(spool.directory, file.Name())
+
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			glog.Errorf("Could not read spool item %s: %v", filename, err)
+			continue
+		}
+
+		var item SpoolItem
+		if err := json.Unmarshal(content, &item); err != nil {
+			glog.Errorf("Could not decode spool item %s: %v", filename, err)
+			continue
+		}
+
+		items = append(items, &item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Created.Before(items[j].Created)
+	})
+
+	return items, nil
+}
+
+// Find returns the spooled item with the specified ID (or unique ID prefix).
+func (spool *Spool) Find(id string) (*SpoolItem, error) {
+
+	items, err := spool.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *SpoolItem
+	for _, item := range items {
+		if strings.HasPrefix(item.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("Spool item ID %s is ambiguous", id)
+			}
+			found = item
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("No spool item with ID %s", id)
+	}
+
+	return found, nil
+}
+
+// nextBackoff returns the delay before the next delivery attempt, doubling with each attempt.
+func (spool *Spool) nextBackoff(attempts int) time.Duration {
+
+	delay := spool.backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= spool.maxBackoff {
+			return spool.maxBackoff
+		}
+	}
+
+	if delay > spool.maxBackoff {
+		return spool.maxBackoff
+	}
+
+	return delay
+}
+
+// IsExpired returns true if the item is older than the spool's maximum age.
+func (spool *Spool) IsExpired(item *SpoolItem, now time.Time) bool {
+
+	return spool.maxAge > 0 && now.Sub(item.Created) > spool.maxAge
+}
+
+// Deliver attempts delivery of a single spooled item, removing it from the
+// spool upon success or scheduling a retry upon failure.
+func (spool *Spool) Deliver(mailer *Mailer, item *SpoolItem) error {
+
+	if mailer == nil {
+		return errors.New("No mailer has been configured")
+	}
+
+	now := time.Now()
+	item.Attempts++
+	item.LastAttempt = now
+
+	if err := mailer.SendMessage(item.Message()); err != nil {
+
+		item.LastError = err.Error()
+		item.NextAttempt = now.Add(spool.nextBackoff(item.Attempts))
+
+		if saveErr := spool.save(item); saveErr != nil {
+			glog.Errorf("Could not update spool item %s: %v", item.ID, saveErr)
+		}
+
+		return err
+	}
+
+	glog.Debugf("Delivered spooled notification %s after %d attempt(s).", item.ID, item.Attempts)
+
+	if err := spool.remove(item); err != nil && !os.IsNotExist(err) {
+		glog.Errorf("Could not remove delivered spool item %s: %v", item.ID, err)
+	}
+
+	return nil
+}
+
+// Submit spools a message and then immediately attempts its delivery.
+// A failed delivery is retained for a later retry.
+func (spool *Spool) Submit(mailer *Mailer, message *MailMessage) error {
+
+	item, err := spool.Add(message)
+	if err != nil {
+		// Spooling failed; a direct delivery attempt is better than nothing.
+		glog.Errorf("Could not spool notification: %v", err)
+		if mailer == nil {
+			return errors.New("No mailer has been configured")
+		}
+		return mailer.SendMessage(message)
+	}
+
+	if err := spool.Deliver(mailer, item); err != nil {
+		glog.Warningf("Could not send mail (will retry after %v): %v", item.NextAttempt.Format(time.RFC3339), err)
+		return err
+	}
+
+	return nil
+}
+
+// Flush attempts delivery of all due spooled items (or all items, if forced)
+// and discards items that have exceeded the maximum age.
+func (spool *Spool) Flush(mailer *Mailer, force bool) SpoolFlushResult {
+
+	var result SpoolFlushResult
+
+	items, err := spool.Items()
+	if err != nil {
+		glog.Errorf("%v", err)
+		return result
+	}
+
+	now := time.Now()
+
+	for _, item := range items {
+
+		if spool.IsExpired(item, now) {
+			glog.Errorf("Discarding expired notification %s (%q) after %d attempt(s); last error: %s", item.ID, item.Subject, item.Attempts, item.LastError)
+			if err := spool.remove(item); err != nil {
+				glog.Errorf("Could not remove expired spool item %s: %v", item.ID, err)
+			}
+			result.Expired++
+			continue
+		}
+
+		if !force && now.Before(item.NextAttempt) {
+			result.Deferred++
+			continue
+		}
+
+		if err := spool.Deliver(mailer, item); err != nil {
+			glog.Warningf("Could not send spooled notification %s: %v", item.ID, err)
+			result.Failed++
+		} else {
+			result.Sent++
+		}
+	}
+
+	return result
+}
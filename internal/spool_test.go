@@ -0,0 +1,62 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestSpool(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "spool")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	spool := NewSpool(directory, time.Hour, time.Minute, 10*time.Minute)
+
+	message := NewMailMessage()
+	message.Sender = "sender@example.com"
+	message.AddRecipients("recipient@example.com")
+	message.Subject = "Subject"
+	message.AddContent("<p>Content</p>")
+
+	item, err := spool.Add(message)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	items, err := spool.Items()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(items).Should(gomega.HaveLen(1))
+	g.Expect(items[0].ID).Should(gomega.Equal(item.ID))
+	g.Expect(items[0].Message().Content()).Should(gomega.Equal("<p>Content</p>"))
+	g.Expect(items[0].Message().Recipients).Should(gomega.Equal([]string{"recipient@example.com"}))
+
+	found, err := spool.Find(item.ID[:10])
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found.Subject).Should(gomega.Equal("Subject"))
+
+	// Without a mailer, delivery fails and the item remains spooled.
+	g.Expect(spool.Deliver(nil, found)).Should(gomega.HaveOccurred())
+
+	// Backoff doubles with each attempt, up to the maximum.
+	g.Expect(spool.nextBackoff(1)).Should(gomega.Equal(time.Minute))
+	g.Expect(spool.nextBackoff(3)).Should(gomega.Equal(4 * time.Minute))
+	g.Expect(spool.nextBackoff(10)).Should(gomega.Equal(10 * time.Minute))
+
+	// Expired items are discarded by a flush.
+	g.Expect(spool.IsExpired(found, time.Now().Add(2*time.Hour))).Should(gomega.BeTrue())
+
+	found.Created = time.Now().Add(-2 * time.Hour)
+	g.Expect(spool.save(found)).ShouldNot(gomega.HaveOccurred())
+
+	result := spool.Flush(nil, true)
+	g.Expect(result.Expired).Should(gomega.Equal(1))
+
+	items, err = spool.Items()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(items).Should(gomega.BeEmpty())
+}
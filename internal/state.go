@@ -0,0 +1,86 @@
+package resticmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// stateName converts an arbitrary name (e.g., a profile name) into a safe state filename component.
+func stateName(name string) string {
+
+	re := regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	name = re.ReplaceAllString(name, "_")
+
+	if name == "" {
+		name = "_"
+	}
+
+	return name
+}
+
+// statePath returns the path of a state file within the state directory.
+func (appConfig *AppConfiguration) statePath(category string, name string) string {
+
+	return filepath.Join(appConfig.StateDir(), category, stateName(name)+".json")
+}
+
+// saveState (atomically) writes a JSON-encoded state object.
+func (appConfig *AppConfiguration) saveState(category string, name string, v interface{}) error {
+
+	filename := appConfig.statePath(category, name)
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return fmt.Errorf("Could not create state directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode state %s: %v", filename, err)
+	}
+
+	if err := ioutil.WriteFile(filename+".tmp", content, 0600); err != nil {
+		return fmt.Errorf("Could not write state %s: %v", filename, err)
+	}
+
+	return os.Rename(filename+".tmp", filename)
+}
+
+// loadState reads a JSON-encoded state object. A missing state file is
+// reported via os.IsNotExist(err).
+func (appConfig *AppConfiguration) loadState(category string, name string, v interface{}) error {
+
+	filename := appConfig.statePath(category, name)
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("Could not decode state %s: %v", filename, err)
+	}
+
+	return nil
+}
+
+// SaveRunRecord persists the report of the most-recent run of a profile.
+func (appConfig *AppConfiguration) SaveRunRecord(report *Report) error {
+
+	return appConfig.saveState("runs", report.Profile.Name, report)
+}
+
+// LoadRunRecord retrieves the report of the most-recent run of a profile.
+func (appConfig *AppConfiguration) LoadRunRecord(profileName string) (*Report, error) {
+
+	var report Report
+
+	if err := appConfig.loadState("runs", profileName, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
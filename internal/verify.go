@@ -0,0 +1,270 @@
+package resticmanager
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Node encapsulates a single snapshot entry, as listed by "restic ls --json".
+type Node struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Path       string      `json:"path"`
+	Size       int64       `json:"size"`
+	Mode       os.FileMode `json:"mode"`
+	ModTime    time.Time   `json:"mtime"`
+	StructType string      `json:"struct_type"`
+}
+
+// ParseNodes parses the output of "restic ls --json", returning the listed
+// entries (but not the leading snapshot description).
+func ParseNodes(output string) ([]Node, error) {
+
+	nodes := make([]Node, 0)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var node Node
+		if err := json.Unmarshal([]byte(line), &node); err != nil {
+			return nil, fmt.Errorf("Could not parse listing: %v", err)
+		}
+		if node.StructType == "node" {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read listing: %v", err)
+	}
+
+	return nodes, nil
+}
+
+// LatestSnapshot returns the most-recent of the snapshots (listed oldest first)
+// that has the specified hostname and includes the specified path (either of
+// which may be empty to match any), or nil if there is none.
+func LatestSnapshot(snapshots []Snapshot, hostname string, path string) *Snapshot {
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		if hostname != "" && snapshot.Hostname != hostname {
+			continue
+		}
+		if path != "" && !containsString(snapshot.Paths, path) {
+			continue
+		}
+		return &snapshots[i]
+	}
+
+	return nil
+}
+
+// FindSnapshot returns the snapshot with the specified ID (full or short, or a
+// unique prefix thereof) or, for "latest", the most-recent snapshot with the
+// specified hostname and path (see LatestSnapshot). nil is returned if there is
+// no such snapshot, and an error if the ID is ambiguous.
+func FindSnapshot(snapshots []Snapshot, id string, hostname string, path string) (*Snapshot, error) {
+
+	if id == "latest" {
+		return LatestSnapshot(snapshots, hostname, path), nil
+	}
+
+	var found *Snapshot
+	for i := range snapshots {
+		if strings.HasPrefix(snapshots[i].ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("Snapshot ID %s is ambiguous", id)
+			}
+			found = &snapshots[i]
+		}
+	}
+
+	return found, nil
+}
+
+// Outcomes of the verification of a single restored file.
+const (
+	// VerifyMatched indicates that the restored file matches the live source.
+	VerifyMatched = "matched"
+	// VerifyDrifted indicates that the live source has changed since the snapshot (which is expected).
+	VerifyDrifted = "drifted"
+	// VerifyMismatched indicates that the restored file does not match the snapshot or the (unchanged) live source.
+	VerifyMismatched = "mismatched"
+)
+
+// FileVerification encapsulates the verification of a single restored file.
+type FileVerification struct {
+	Path    string
+	Outcome string
+	Detail  string
+}
+
+// RestoreVerification encapsulates the outcome of a restore drill.
+type RestoreVerification struct {
+	Snapshot string
+	Files    []FileVerification
+}
+
+// Count returns the number of files with the specified outcome.
+func (verification *RestoreVerification) Count(outcome string) int {
+
+	count := 0
+	for _, file := range verification.Files {
+		if file.Outcome == outcome {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Mismatches returns the files that failed verification.
+func (verification *RestoreVerification) Mismatches() []FileVerification {
+
+	mismatches := make([]FileVerification, 0)
+	for _, file := range verification.Files {
+		if file.Outcome == VerifyMismatched {
+			mismatches = append(mismatches, file)
+		}
+	}
+
+	return mismatches
+}
+
+// selectFiles returns the (regular) files to restore: all files at or beneath
+// the critical paths, along with a random sample of (up to) count other files.
+func selectFiles(nodes []Node, critical []string, count int, random *rand.Rand) []Node {
+
+	selected := make([]Node, 0)
+	others := make([]Node, 0)
+
+	for _, node := range nodes {
+		if node.Type != "file" {
+			continue
+		}
+
+		isCritical := false
+		for _, path := range critical {
+			if node.Path == path || strings.HasPrefix(node.Path, strings.TrimSuffix(path, "/")+"/") {
+				isCritical = true
+				break
+			}
+		}
+
+		if isCritical {
+			selected = append(selected, node)
+		} else {
+			others = append(others, node)
+		}
+	}
+
+	random.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+	if count < len(others) {
+		others = others[:count]
+	}
+	selected = append(selected, others...)
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Path < selected[j].Path })
+
+	return selected
+}
+
+// fileHash returns the (hex) SHA-256 hash of the content of a file.
+func fileHash(filename string) (string, error) {
+
+	handle, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer handle.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, handle); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyFile compares a restored file with its snapshot entry and the live
+// source. The restored file must match the snapshot metadata (size and
+// permissions); if the live source is unchanged since the snapshot (same size
+// and modification time), the content must also match, otherwise the file has
+// (expectedly) drifted.
+func verifyFile(node Node, restored string) FileVerification {
+
+	verification := FileVerification{Path: node.Path, Outcome: VerifyMismatched}
+
+	restoredInfo, err := os.Stat(restored)
+	if err != nil {
+		verification.Detail = fmt.Sprintf("not restored: %v", err)
+		return verification
+	}
+	if restoredInfo.Size() != node.Size {
+		verification.Detail = fmt.Sprintf("restored size %d differs from snapshot size %d", restoredInfo.Size(), node.Size)
+		return verification
+	}
+	if restoredInfo.Mode().Perm() != node.Mode.Perm() {
+		verification.Detail = fmt.Sprintf("restored permissions %v differ from snapshot permissions %v", restoredInfo.Mode().Perm(), node.Mode.Perm())
+		return verification
+	}
+
+	liveInfo, err := os.Stat(node.Path)
+	if err != nil {
+		verification.Outcome = VerifyDrifted
+		verification.Detail = "removed since the snapshot"
+		return verification
+	}
+	if liveInfo.Size() != node.Size || !liveInfo.ModTime().Equal(node.ModTime) {
+		verification.Outcome = VerifyDrifted
+		verification.Detail = "modified since the snapshot"
+		return verification
+	}
+
+	restoredHash, err := fileHash(restored)
+	if err != nil {
+		verification.Detail = fmt.Sprintf("could not read restored file: %v", err)
+		return verification
+	}
+	liveHash, err := fileHash(node.Path)
+	if err != nil {
+		verification.Detail = fmt.Sprintf("could not read source file: %v", err)
+		return verification
+	}
+	if restoredHash != liveHash {
+		verification.Detail = fmt.Sprintf("restored content (sha256 %.12s) differs from unchanged source (sha256 %.12s)", restoredHash, liveHash)
+		return verification
+	}
+
+	verification.Outcome = VerifyMatched
+
+	return verification
+}
+
+// verifyRestored verifies each of the selected files, as restored beneath a target directory.
+func verifyRestored(snapshot string, nodes []Node, target string) *RestoreVerification {
+
+	verification := &RestoreVerification{Snapshot: snapshot}
+
+	for _, node := range nodes {
+		verification.Files = append(verification.Files, verifyFile(node, filepath.Join(target, filepath.FromSlash(node.Path))))
+	}
+
+	return verification
+}
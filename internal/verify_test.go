@@ -0,0 +1,141 @@
+package resticmanager
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseNodes(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	output := `{"time":"2024-03-01T02:00:00Z","hostname":"nas","paths":["/home"],"id":"1a2b","short_id":"1a2b","struct_type":"snapshot"}
+{"name":"home","type":"dir","path":"/home","mode":2147484141,"mtime":"2024-03-01T01:00:00Z","struct_type":"node"}
+{"name":"a.txt","type":"file","path":"/home/a.txt","size":5,"mode":420,"mtime":"2024-03-01T01:00:00Z","struct_type":"node"}
+`
+
+	nodes, err := ParseNodes(output)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(nodes).Should(gomega.HaveLen(2))
+	g.Expect(nodes[1].Path).Should(gomega.Equal("/home/a.txt"))
+	g.Expect(nodes[1].Size).Should(gomega.Equal(int64(5)))
+	g.Expect(nodes[1].Mode.Perm()).Should(gomega.Equal(os.FileMode(0644)))
+
+	_, err = ParseNodes("{not json")
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	// Critical paths are always selected; others are sampled
+	files := []Node{
+		{Type: "dir", Path: "/home/db"},
+		{Type: "file", Path: "/home/db/data"},
+		{Type: "file", Path: "/home/dbx"},
+		{Type: "file", Path: "/home/a"},
+		{Type: "file", Path: "/home/b"},
+	}
+	selected := selectFiles(files, []string{"/home/db"}, 1, rand.New(rand.NewSource(1)))
+	g.Expect(selected).Should(gomega.HaveLen(2))
+	g.Expect(selected).Should(gomega.ContainElement(files[1]))
+	g.Expect(selectFiles(files, nil, 10, rand.New(rand.NewSource(1)))).Should(gomega.HaveLen(4))
+
+	snapshots := []Snapshot{
+		{ID: "a", Hostname: "nas", Paths: []string{"/home"}},
+		{ID: "b", Hostname: "nas", Paths: []string{"/srv"}},
+		{ID: "c", Hostname: "laptop", Paths: []string{"/home"}},
+	}
+	g.Expect(LatestSnapshot(snapshots, "nas", "/home").ID).Should(gomega.Equal("a"))
+	g.Expect(LatestSnapshot(snapshots, "", "").ID).Should(gomega.Equal("c"))
+	g.Expect(LatestSnapshot(snapshots, "nas", "/var")).Should(gomega.BeNil())
+}
+
+func TestFindSnapshot(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	// Snapshots of several hosts sharing a repository (oldest first); the newest is not of the profile
+	snapshots := []Snapshot{
+		{ID: "1a2b", Hostname: "nas", Paths: []string{"/home"}},
+		{ID: "1c3d", Hostname: "laptop", Paths: []string{"/home"}},
+		{ID: "2e4f", Hostname: "nas", Paths: []string{"/home"}},
+		{ID: "3a5b", Hostname: "nas", Paths: []string{"/srv"}},
+		{ID: "4c6d", Hostname: "laptop", Paths: []string{"/home"}},
+	}
+
+	snapshot, err := FindSnapshot(snapshots, "latest", "nas", "/home")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(snapshot.ID).Should(gomega.Equal("2e4f"))
+
+	snapshot, err = FindSnapshot(snapshots, "latest", "laptop", "/home")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(snapshot.ID).Should(gomega.Equal("4c6d"))
+
+	snapshot, err = FindSnapshot(snapshots, "latest", "desktop", "/home")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(snapshot).Should(gomega.BeNil())
+
+	// IDs are matched by (unique) prefix, irrespective of host
+	snapshot, err = FindSnapshot(snapshots, "1c", "nas", "/home")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(snapshot.ID).Should(gomega.Equal("1c3d"))
+
+	_, err = FindSnapshot(snapshots, "1", "nas", "/home")
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	snapshot, err = FindSnapshot(snapshots, "ffff", "nas", "/home")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(snapshot).Should(gomega.BeNil())
+}
+
+func TestVerifyRestored(t *testing.T) {
+
+	g := gomega.NewGomegaWithT(t)
+
+	directory, err := ioutil.TempDir("", "verify")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer os.RemoveAll(directory)
+
+	source := filepath.Join(directory, "source")
+	target := filepath.Join(directory, "target")
+	snapshotTime := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	// write creates a file with the specified content and modification time
+	write := func(filename string, content string, mtime time.Time) {
+		g.Expect(os.MkdirAll(filepath.Dir(filename), 0700)).Should(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filename, []byte(content), 0644)).Should(gomega.Succeed())
+		g.Expect(os.Chmod(filename, 0644)).Should(gomega.Succeed())
+		g.Expect(os.Chtimes(filename, mtime, mtime)).Should(gomega.Succeed())
+	}
+
+	node := func(name string, size int64) Node {
+		return Node{Type: "file", Path: filepath.Join(source, name), Size: size, Mode: 0644, ModTime: snapshotTime}
+	}
+	nodes := []Node{node("same", 5), node("changed", 5), node("removed", 5), node("corrupt", 5), node("missing", 5)}
+
+	for _, name := range []string{"same", "changed", "removed", "corrupt"} {
+		write(filepath.Join(target, source, name), "hello", snapshotTime)
+	}
+	write(filepath.Join(source, "same"), "hello", snapshotTime)
+	write(filepath.Join(source, "changed"), "hello, world", snapshotTime.Add(time.Hour))
+	write(filepath.Join(source, "corrupt"), "jello", snapshotTime)
+	write(filepath.Join(source, "missing"), "hello", snapshotTime)
+
+	verification := verifyRestored("1a2b", nodes, target)
+
+	outcomes := make(map[string]string)
+	for _, file := range verification.Files {
+		outcomes[filepath.Base(file.Path)] = file.Outcome
+	}
+	g.Expect(outcomes).Should(gomega.Equal(map[string]string{
+		"same":    VerifyMatched,
+		"changed": VerifyDrifted,
+		"removed": VerifyDrifted,
+		"corrupt": VerifyMismatched,
+		"missing": VerifyMismatched,
+	}))
+	g.Expect(verification.Mismatches()).Should(gomega.HaveLen(2))
+}
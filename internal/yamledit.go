@@ -0,0 +1,265 @@
+package resticmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// yamlDocument holds YAML content along with its parsed node tree, allowing
+// targeted edits of scalar values that preserve the layout and comments of the
+// remainder of the document.
+type yamlDocument struct {
+	content []byte
+	root    *yamlv3.Node
+}
+
+// yamlScalarEdit describes the replacement of a scalar node's value. The value
+// is a string (quoted as required) unless literal is set, in which case it is
+// written verbatim (e.g., for booleans and numbers).
+type yamlScalarEdit struct {
+	node    *yamlv3.Node
+	value   string
+	literal bool
+}
+
+// parseYAMLDocument parses YAML content into a yamlDocument.
+func parseYAMLDocument(content []byte) (*yamlDocument, error) {
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	doc := &yamlDocument{content: content}
+	if len(document.Content) > 0 {
+		doc.root = document.Content[0]
+	}
+
+	return doc, nil
+}
+
+// lineOffset returns the byte offset of the start of a (1-based) line.
+func (doc *yamlDocument) lineOffset(line int) (int, error) {
+
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := bytes.IndexByte(doc.content[offset:], '\n')
+		if next < 0 {
+			return 0, fmt.Errorf("line %d is beyond the end of the document", line)
+		}
+		offset += next + 1
+	}
+
+	return offset, nil
+}
+
+// scalarExtent returns the byte range of a (single-line) scalar node's text within the document.
+func (doc *yamlDocument) scalarExtent(node *yamlv3.Node) (int, int, error) {
+
+	if node.Kind != yamlv3.ScalarNode {
+		return 0, 0, errors.New("not a scalar value")
+	}
+
+	lineStart, err := doc.lineOffset(node.Line)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	lineEnd := bytes.IndexByte(doc.content[lineStart:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(doc.content)
+	} else {
+		lineEnd += lineStart
+	}
+	line := string(doc.content[lineStart:lineEnd])
+
+	// Columns count characters, not bytes
+	runes := []rune(line)
+	if node.Column-1 > len(runes) {
+		return 0, 0, fmt.Errorf("column %d is beyond the end of line %d", node.Column, node.Line)
+	}
+	start := len(string(runes[:node.Column-1]))
+	text := line[start:]
+
+	switch node.Style {
+
+	case 0:
+		if strings.Contains(node.Value, "\n") || !strings.HasPrefix(text, node.Value) {
+			return 0, 0, fmt.Errorf("unsupported multi-line value at line %d", node.Line)
+		}
+		return lineStart + start, lineStart + start + len(node.Value), nil
+
+	case yamlv3.DoubleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '"':
+				return lineStart + start, lineStart + start + i + 1, nil
+			}
+		}
+
+	case yamlv3.SingleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return lineStart + start, lineStart + start + i + 1, nil
+			}
+		}
+	}
+
+	return 0, 0, fmt.Errorf("unsupported (e.g., multi-line or block) value at line %d", node.Line)
+}
+
+// formatScalar formats a value as YAML scalar text, retaining the original
+// quoting style where possible.
+func formatScalar(value string, style yamlv3.Style) string {
+
+	if style == yamlv3.SingleQuotedStyle && !strings.Contains(value, "\n") {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+
+	if style == 0 && isPlainSafe(value) {
+		return value
+	}
+
+	// A JSON string is a valid YAML double-quoted scalar
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+
+	return strings.TrimSpace(buffer.String())
+}
+
+// isPlainSafe returns true if a value can be written as a plain (unquoted)
+// scalar, in both block and flow context, and be read back unchanged.
+func isPlainSafe(value string) bool {
+
+	if value == "" || strings.ContainsAny(value, ",[]{}\n") {
+		return false
+	}
+
+	var parsed interface{}
+	if err := yamlv3.Unmarshal([]byte("v: "+value), &parsed); err != nil {
+		return false
+	}
+
+	m, ok := parsed.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	s, ok := m["v"].(string)
+	return ok && s == value
+}
+
+// applyScalarEdits returns the document content with the specified scalar edits applied.
+func (doc *yamlDocument) applyScalarEdits(edits []yamlScalarEdit) ([]byte, error) {
+
+	type splice struct {
+		start int
+		end   int
+		text  string
+	}
+
+	splices := make([]splice, 0, len(edits))
+	for _, edit := range edits {
+		start, end, err := doc.scalarExtent(edit.node)
+		if err != nil {
+			return nil, err
+		}
+		text := edit.value
+		if !edit.literal {
+			text = formatScalar(edit.value, edit.node.Style)
+		}
+		splices = append(splices, splice{start, end, text})
+	}
+
+	// Apply from the end of the document backwards, so that earlier offsets remain valid
+	sort.Slice(splices, func(i, j int) bool {
+		return splices[i].start > splices[j].start
+	})
+
+	content := append([]byte{}, doc.content...)
+	for _, s := range splices {
+		content = append(content[:s.start], append([]byte(s.text), content[s.end:]...)...)
+	}
+
+	return content, nil
+}
+
+// walkScalarValues calls visit for every scalar value (i.e., not mapping keys)
+// within a node, along with its dotted key.
+func walkScalarValues(node *yamlv3.Node, key string, visit func(key string, node *yamlv3.Node)) {
+
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+
+	case yamlv3.ScalarNode:
+		visit(key, node)
+
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			walkScalarValues(item, fmt.Sprintf("%s[%d]", key, i), visit)
+		}
+
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkScalarValues(node.Content[i+1], joinKey(key, node.Content[i].Value), visit)
+		}
+	}
+}
+
+// topLevelValue returns the value node of a top-level key (matched case-insensitively), or nil if not present.
+func (doc *yamlDocument) topLevelValue(key string) *yamlv3.Node {
+
+	if doc.root == nil || doc.root.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(doc.root.Content); i += 2 {
+		if strings.EqualFold(doc.root.Content[i].Value, key) {
+			return doc.root.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// setTopLevelScalar returns the document content with a top-level key set to
+// a literal scalar value (e.g., "true"). An existing value is replaced in place;
+// otherwise, the key is appended to the end of the document.
+func (doc *yamlDocument) setTopLevelScalar(key string, value string) ([]byte, error) {
+
+	if node := doc.topLevelValue(key); node != nil {
+		if node.Kind != yamlv3.ScalarNode {
+			return nil, fmt.Errorf("%s is not a scalar value", key)
+		}
+		return doc.applyScalarEdits([]yamlScalarEdit{{node: node, value: value, literal: true}})
+	}
+
+	if doc.root != nil && (doc.root.Kind != yamlv3.MappingNode || doc.root.Style&yamlv3.FlowStyle != 0) {
+		return nil, errors.New("the document is not a block mapping")
+	}
+
+	content := append([]byte{}, doc.content...)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, []byte(fmt.Sprintf("%s: %s\n", key, value))...)
+
+	return content, nil
+}